@@ -0,0 +1,873 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ToDoItem is a single entry on a users to do list. Id is the position of
+// the item in sorted output and changes as items come and go, ItemId is
+// assigned when the item is created and never changes.
+type ToDoItem struct {
+	Id      int       `json:"number,omitempty"`
+	ItemId  string    `json:"id"`
+	Item    string    `json:"item"`
+	Done    bool      `json:"done,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Notes   string    `json:"notes,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Parent  string    `json:"parent,omitempty"`
+	// Repeat is the items recurrence rule, see ParseRecurrence
+	Repeat    string    `json:"repeat,omitempty"`
+	Due       time.Time `json:"due,omitzero"`
+	Completed time.Time `json:"completed,omitzero"`
+	// Reminders are how long before Due to remind, see ParseReminder
+	Reminders []string  `json:"reminders,omitempty"`
+	Reminded  time.Time `json:"reminded,omitzero"`
+	// Version goes up every time the item changes
+	Version int64 `json:"version,omitempty"`
+	// Priority is a letter, A the highest, see ParsePriority
+	Priority string `json:"priority,omitempty"`
+	// Rank places the item in manual order, see orderedKeys
+	Rank int64 `json:"rank,omitempty"`
+	// Projects, Contexts and Extensions are the +project, @context and
+	// key:value words of a todo.txt item, see parseToDoTxt
+	Projects   []string          `json:"projects,omitempty"`
+	Contexts   []string          `json:"contexts,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+type baseToDoList map[int]ToDoItem
+
+var NotFoundErr = fmt.Errorf("not found")
+var AlreadyExistsErr = fmt.Errorf("already exists")
+
+type JobType int
+type LogType int
+
+const (
+	LoadData = iota
+	FetchData
+	AddData
+	UpdateData
+	DeleteData
+	StoreData
+	CompleteData
+	ReopenData
+	RestoreData
+	TagData
+	UntagData
+	FilterData
+	CreateListData
+	RenameListData
+	DeleteListData
+	FetchListsData
+	MoveData
+	RepeatData
+	DueData
+	RemindData
+	SearchData
+	UndoData
+	RedoData
+	BatchData
+	ReorderData
+	PriorityData
+	ImportData
+	ExportData
+)
+
+const (
+	InfoLog  = 1
+	ErrorLog = 2
+)
+
+type ReturnChannelData struct {
+	List  map[int]ToDoItem
+	Lists []string
+	// Found holds the results of a search, best match first
+	Found []SearchResult
+	// Op names the change undone or redone
+	Op string
+	// Results says what happened to each change in a batch
+	Results []BatchResult
+	// Version is the version of the list the job worked on, set along with
+	// List
+	Version int64
+	// Items holds the items exported
+	Items []ExportItem
+	// Imported says what an import added and skipped
+	Imported ImportResult
+	Err      error
+}
+
+// DataStoreJob is a request to the data job queue. List names the users
+// list it works on, empty for the default list. Batch holds the changes a
+// batch job makes and Items the items an import adds, DryRun only reports
+// what it would add. ListVersion and Version, when set, are the versions of
+// the list and of the item in KeyValue a change expects, it fails with
+// VersionConflictErr when either has moved on. Repeat, on an add, is the
+// repeat rule the new item is added with.
+type DataStoreJob struct {
+	Context       context.Context
+	Uid           string
+	List          string
+	JobType       JobType
+	KeyValue      string
+	AltValue      string
+	Batch         []BatchOp
+	Items         []ExportItem
+	DryRun        bool
+	ListVersion   int64
+	Version       int64
+	Repeat        string
+	ReturnChannel chan ReturnChannelData
+}
+
+type LoggerJob struct {
+	LogType    LogType
+	Context    context.Context
+	LogMessage string
+}
+
+// ToDoStore is a self contained to do list store. it has its own job
+// queues, the workers that process them, a logger and the backend Store
+// that holds the lists.
+type ToDoStore struct {
+	DataJobQueue   chan DataStoreJob
+	LoggerJobQueue chan LoggerJob
+	Logger         *slog.Logger
+
+	store       Store
+	mutex       sync.RWMutex
+	dataWorkers int
+	childPolicy ChildPolicy
+	logFile     io.Closer
+	workers     sync.WaitGroup
+	closeOnce   sync.Once
+
+	clock            Clock
+	notifiers        []Notifier
+	reminderInterval time.Duration
+	// closed to stop the reminder scheduler
+	stop chan struct{}
+
+	history     history
+	audit       AuditLog
+	auditOnce   sync.Once
+	subscribers subscribers
+}
+
+// Option configures a store created by New
+type Option func(*ToDoStore) error
+
+// WithStore sets the backend, the default is the flat file todo.txt
+func WithStore(store Store) Option {
+	return func(s *ToDoStore) error {
+		s.store = store
+		return nil
+	}
+}
+
+// WithLogger sends the stores log to logger instead of its own log file
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *ToDoStore) error {
+		s.Logger = logger
+		return nil
+	}
+}
+
+// WithLogFile sets the file the store logs to
+func WithLogFile(filename string) Option {
+	return func(s *ToDoStore) error {
+		s.Logger, s.logFile = newFileLogger(filename)
+		return nil
+	}
+}
+
+// WithQueueSize sets how many jobs can wait on each queue
+func WithQueueSize(size int) Option {
+	return func(s *ToDoStore) error {
+		if size < 0 {
+			return fmt.Errorf("queue size %d is negative", size)
+		}
+		s.DataJobQueue = make(chan DataStoreJob, size)
+		s.LoggerJobQueue = make(chan LoggerJob, size)
+		return nil
+	}
+}
+
+// WithWorkers sets how many workers process data jobs, the default is one
+// per cpu
+func WithWorkers(workers int) Option {
+	return func(s *ToDoStore) error {
+		if workers < 1 {
+			return fmt.Errorf("%d workers, need at least one", workers)
+		}
+		s.dataWorkers = workers
+		return nil
+	}
+}
+
+// New creates a store and starts its workers and reminder scheduler. Close
+// stops them.
+func New(opts ...Option) (*ToDoStore, error) {
+	s := &ToDoStore{
+		DataJobQueue:   make(chan DataStoreJob, 1000),
+		LoggerJobQueue: make(chan LoggerJob, 1000),
+		stop:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.Logger == nil {
+		s.Logger, s.logFile = newFileLogger(fmt.Sprintf("todo-%d.log", time.Now().UnixMicro()))
+	}
+	if setter, ok := s.store.(loggerSetter); ok {
+		setter.setLogger(s.Logger)
+	}
+
+	s.workers.Add(3)
+	go func() {
+		defer s.workers.Done()
+		s.ProcessDataJobs()
+	}()
+	go func() {
+		defer s.workers.Done()
+		s.ProcessLoggerJobs()
+	}()
+	go func() {
+		defer s.workers.Done()
+		s.ProcessReminders()
+	}()
+	return s, nil
+}
+
+// Close stops the workers once the jobs already queued have been processed
+// and releases the backend and log file. nothing may be sent to the queues
+// after Close is called.
+func (s *ToDoStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.DataJobQueue)
+		close(s.LoggerJobQueue)
+		if s.stop != nil {
+			close(s.stop)
+		}
+		s.workers.Wait()
+		s.subscribers.closeAll()
+
+		err = s.closeHistory()
+		if closer, ok := s.store.(io.Closer); ok {
+			err = errors.Join(err, closer.Close())
+		}
+		if s.logFile != nil {
+			err = errors.Join(err, s.logFile.Close())
+		}
+	})
+	return err
+}
+
+// UseStore sets the backend used by the job queue and the Basic* functions.
+// call it before queueing any jobs.
+func (s *ToDoStore) UseStore(store Store) {
+	s.store = store
+	if setter, ok := store.(loggerSetter); ok {
+		setter.setLogger(s.Logger)
+	}
+}
+
+// activeStore returns the backend in use, falling back to todo.txt when
+// none has been set
+func (s *ToDoStore) activeStore() Store {
+	if s.store == nil {
+		s.UseStore(NewFileStore("todo.txt"))
+	}
+	return s.store
+}
+
+// ListBackups returns the backups kept by the backend, newest first
+func (s *ToDoStore) ListBackups() ([]string, error) {
+	restorer, ok := s.activeStore().(Restorer)
+	if !ok {
+		return nil, NotSupportedErr
+	}
+	return restorer.Backups()
+}
+
+// ProcessDataJobs processes the data job queue until it is closed. jobs are
+// handed to a fixed set of workers by a hash of their Uid, so each users
+// jobs run in the order they were queued while different users run in
+// parallel. load, store, restore and batch jobs can touch any list, they
+// wait for the jobs queued before them to finish and run on their own.
+func (s *ToDoStore) ProcessDataJobs() {
+	workers := s.dataWorkers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var running sync.WaitGroup
+	// jobs handed to a worker that haven't finished yet
+	var pending sync.WaitGroup
+	partitions := make([]chan DataStoreJob, workers)
+	for i := range partitions {
+		partitions[i] = make(chan DataStoreJob, cap(s.DataJobQueue))
+		running.Add(1)
+		go func(partition chan DataStoreJob) {
+			defer running.Done()
+			for v := range partition {
+				s.mutex.RLock()
+				s.processDataJob(v)
+				s.mutex.RUnlock()
+				pending.Done()
+			}
+		}(partitions[i])
+	}
+
+	for v := range s.DataJobQueue {
+		// until the first job has picked the backend it runs on its own
+		if exclusiveJob(v.JobType) || s.store == nil {
+			pending.Wait()
+			s.mutex.Lock()
+			s.processDataJob(v)
+			s.mutex.Unlock()
+			continue
+		}
+		pending.Add(1)
+		partitions[partition(v.Uid, workers)] <- v
+	}
+
+	for _, v := range partitions {
+		close(v)
+	}
+	running.Wait()
+}
+
+func (s *ToDoStore) processDataJob(v DataStoreJob) {
+	if v.Context != nil && v.Context.Err() != nil {
+		s.skipJob(v)
+		return
+	}
+	if op, found := undoableJobs[v.JobType]; found {
+		if err := s.checkVersion(v); err != nil {
+			defer close(v.ReturnChannel)
+			s.reply(v, ReturnChannelData{Err: err})
+			return
+		}
+		// the caller is answered once the change is in the saved history
+		job := v
+		job.ReturnChannel = make(chan ReturnChannelData, 1)
+		s.recordChange(v.Context, v.key(), op, func() error {
+			s.runDataJob(job)
+			return nil
+		})
+		defer close(v.ReturnChannel)
+		for data := range job.ReturnChannel {
+			s.reply(v, data)
+		}
+		return
+	}
+	s.runDataJob(v)
+}
+
+func (s *ToDoStore) runDataJob(v DataStoreJob) {
+	switch v.JobType {
+	case LoadData:
+		s.LoadToDoList(v)
+	case FetchData:
+		s.FetchToDoList(v)
+	case AddData:
+		s.AddToDoItem(v)
+	case UpdateData:
+		s.UpdateToDoItem(v)
+	case DeleteData:
+		s.DeleteToDoItem(v)
+	case StoreData:
+		s.PersistEntries(v)
+	case CompleteData:
+		s.CompleteToDoItem(v)
+	case ReopenData:
+		s.ReopenToDoItem(v)
+	case RestoreData:
+		s.RestoreToDoList(v)
+	case TagData:
+		s.TagToDoItem(v)
+	case UntagData:
+		s.UntagToDoItem(v)
+	case FilterData:
+		s.FilterToDoList(v)
+	case CreateListData:
+		s.CreateList(v)
+	case RenameListData:
+		s.RenameList(v)
+	case DeleteListData:
+		s.DeleteList(v)
+	case FetchListsData:
+		s.FetchLists(v)
+	case MoveData:
+		s.MoveToDoItem(v)
+	case RepeatData:
+		s.RepeatToDoItem(v)
+	case DueData:
+		s.DueToDoItem(v)
+	case RemindData:
+		s.RemindToDoItem(v)
+	case SearchData:
+		s.SearchToDoList(v)
+	case UndoData:
+		s.UndoToDoList(v)
+	case RedoData:
+		s.RedoToDoList(v)
+	case BatchData:
+		s.BatchToDoList(v)
+	case ReorderData:
+		s.ReorderToDoItem(v)
+	case PriorityData:
+		s.PriorityToDoItem(v)
+	case ImportData:
+		s.ImportToDoList(v)
+	case ExportData:
+		s.ExportToDoList(v)
+	}
+}
+
+// exclusiveJob reports whether a job works on every list rather than one
+// users list
+func exclusiveJob(jobType JobType) bool {
+	switch jobType {
+	case LoadData, StoreData, RestoreData, BatchData:
+		return true
+	}
+	return false
+}
+
+// partition returns the worker that processes a users jobs
+func partition(uid string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	return int(h.Sum32() % uint32(workers))
+}
+
+func (s *ToDoStore) ProcessLoggerJobs() {
+	for v := range s.LoggerJobQueue {
+		switch v.LogType {
+		case InfoLog:
+			s.Logger.InfoContext(v.Context, v.LogMessage)
+		case ErrorLog:
+			s.Logger.ErrorContext(v.Context, v.LogMessage)
+		default:
+			s.Logger.InfoContext(v.Context, v.LogMessage)
+		}
+	}
+}
+
+// GetUserList returns a copy of a users list from the store in use
+func (s *ToDoStore) GetUserList(uid string) map[int]ToDoItem {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	userlist, err := s.activeStore().Fetch(uid)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("error %v fetching list", err))
+		userlist = make(map[int]ToDoItem)
+	}
+	return userlist
+}
+
+// NewToDoItem creates a new item with a fresh time ordered id
+func NewToDoItem(item string) ToDoItem {
+	now := time.Now()
+	return ToDoItem{
+		ItemId:  uuid.Must(uuid.NewV7()).String(),
+		Item:    item,
+		Created: now,
+		Updated: now,
+		Version: 1,
+	}
+}
+
+// LoadToDoList loads the store in use. if UseStore hasn't been called the
+// todo file named in KeyValue is used.
+func (s *ToDoStore) LoadToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelValue := ReturnChannelData{}
+
+	if s.store == nil && dataJob.KeyValue != "" {
+		s.UseStore(NewFileStore(dataJob.KeyValue))
+	}
+	err := s.activeStore().Load()
+	if err == nil {
+		err = s.loadHistory()
+	}
+	if err != nil {
+		s.Logger.ErrorContext(dataJob.Context, fmt.Sprintf("error %v loading todo list", err))
+		returnChannelValue.Err = err
+		s.reply(dataJob, returnChannelValue)
+		return
+	}
+	returnChannelValue.List, returnChannelValue.Err = s.activeStore().Fetch(dataJob.key())
+	s.reply(dataJob, returnChannelValue)
+}
+
+// AddToDoItem adds KeyValue to the list, as a sub-task of the item in
+// AltValue if it is set
+func (s *ToDoStore) AddToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.addItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue, dataJob.Repeat)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) UpdateToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.changeItem(dataJob.key(), dataJob.KeyValue, func(todo *ToDoItem) {
+		todo.Item = dataJob.AltValue
+	})
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) DeleteToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.deleteItem(dataJob.key(), dataJob.KeyValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) CompleteToDoItem(dataJob DataStoreJob) {
+	s.setItemDone(dataJob, true)
+}
+
+func (s *ToDoStore) ReopenToDoItem(dataJob DataStoreJob) {
+	s.setItemDone(dataJob, false)
+}
+
+func (s *ToDoStore) setItemDone(dataJob DataStoreJob, done bool) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.setDone(dataJob.key(), dataJob.KeyValue, done)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) BasicLoadToDoList() error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	err := s.activeStore().Load()
+	if err == nil {
+		err = s.loadHistory()
+	}
+	if err != nil {
+		s.Logger.ErrorContext(context.Background(), fmt.Sprintf("error %v loading todo list", err))
+		return err
+	}
+	return nil
+}
+
+func (s *ToDoStore) BasicPersistEntries() error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	if err := s.activeStore().Persist(); err != nil {
+		return err
+	}
+	return s.saveHistory()
+}
+
+func (s *ToDoStore) BasicAddToDoItem(uid string, item string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "add", func() error {
+		_, err := s.addItem(uid, item, "", "")
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicUpdateToDoItem(uid string, item string, replacewith string) error {
+	return s.BasicUpdateToDoItemVersion(uid, item, replacewith, 0, 0)
+}
+
+func (s *ToDoStore) BasicDeleteToDoItem(uid string, item string) error {
+	return s.BasicDeleteToDoItemVersion(uid, item, 0, 0)
+}
+
+func (s *ToDoStore) BasicCompleteToDoItem(uid string, item string) error {
+	return s.basicSetItemDone(uid, item, true)
+}
+
+func (s *ToDoStore) BasicReopenToDoItem(uid string, item string) error {
+	return s.basicSetItemDone(uid, item, false)
+}
+
+func (s *ToDoStore) basicSetItemDone(uid string, item string, done bool) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	op := "reopen"
+	if done {
+		op = "done"
+	}
+	return s.recordChange(context.Background(), uid, op, func() error {
+		_, err := s.setDone(uid, item, done)
+		return err
+	})
+}
+
+func (s *ToDoStore) FetchToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.activeStore().Fetch(dataJob.key())
+	s.reply(dataJob, returnChannelData)
+}
+
+func SortedMap(userlist map[int]ToDoItem) []ToDoItem {
+
+	sortedmap := make([]ToDoItem, 0)
+
+	keys := make([]int, 0, len(userlist))
+	for idx, _ := range userlist {
+		keys = append(keys, idx)
+	}
+	sort.Ints(keys)
+	index := 1
+	for _, v := range keys {
+		item := userlist[v]
+		if item.Id == 0 {
+			item.Id = index
+		}
+		sortedmap = append(sortedmap, item)
+		index += 1
+	}
+	return sortedmap
+}
+
+func (s *ToDoStore) PersistEntries(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	err := s.activeStore().Persist()
+	if err == nil {
+		err = s.saveHistory()
+	}
+	if err != nil {
+		returnChannelData.Err = err
+	}
+	s.reply(dataJob, returnChannelData)
+}
+
+// RestoreToDoList rolls the store back to the backup named in AltValue
+func (s *ToDoStore) RestoreToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	restorer, ok := s.activeStore().(Restorer)
+	if !ok {
+		returnChannelData.Err = NotSupportedErr
+		s.reply(dataJob, returnChannelData)
+		return
+	}
+	err := restorer.Restore(dataJob.AltValue)
+	if err != nil {
+		s.Logger.ErrorContext(dataJob.Context, fmt.Sprintf("error %v restoring backup", err))
+		returnChannelData.Err = err
+		s.reply(dataJob, returnChannelData)
+		return
+	}
+	// the changes in it are not the ones that led to the backup
+	s.history.clear()
+	if err := s.saveHistory(); err != nil {
+		s.Logger.ErrorContext(dataJob.Context, fmt.Sprintf("error %v clearing history", err))
+	}
+	s.auditChange(dataJob.Context, dataJob.Uid, historyEntry{Op: "restore", Time: s.now()}, dataJob.AltValue)
+	returnChannelData.List, returnChannelData.Err = s.activeStore().Fetch(dataJob.key())
+	s.reply(dataJob, returnChannelData)
+}
+
+// newItem is NewToDoItem created at the time on the stores clock
+func (s *ToDoStore) newItem(text string) ToDoItem {
+	todo := NewToDoItem(text)
+	todo.Created = s.now()
+	todo.Updated = todo.Created
+	return todo
+}
+
+// addItem adds a new item to a users list, as a sub-task of the item
+// parentKey refers to if it is set, unless its text is already there. an
+// item with a repeat rule is due when the rule first comes round.
+func (s *ToDoStore) addItem(uid string, text string, parentKey string, rule string) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(uid)
+	if err != nil {
+		return nil, err
+	}
+	todo := s.newItem(text)
+	todo.Rank = lastRank(userlist)
+	if err := newRecurring(&todo, rule, s.now()); err != nil {
+		return nil, err
+	}
+	pidx := -1
+	if parentKey != "" {
+		pidx = findItem(userlist, parentKey)
+		if pidx == -1 {
+			return nil, fmt.Errorf("parent %w", NotFoundErr)
+		}
+		todo.Parent = userlist[pidx].ItemId
+	}
+	if siblingExists(userlist, todo.Parent, text) {
+		return nil, AlreadyExistsErr
+	}
+	if err := store.Add(uid, todo); err != nil {
+		return nil, err
+	}
+	if pidx != -1 {
+		// a done parent gets an open sub-task
+		idx := getNewKey(userlist)
+		userlist[idx] = todo
+		if err := reopenAncestors(store, uid, userlist, idx, todo.Created); err != nil {
+			return nil, err
+		}
+	}
+	return store.Fetch(uid)
+}
+
+// changeItem applies change to the item key refers to, see findItem
+func (s *ToDoStore) changeItem(uid string, key string, change func(todo *ToDoItem)) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(uid)
+	if err != nil {
+		return nil, err
+	}
+	idx := findItem(userlist, key)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+	todo := userlist[idx]
+	change(&todo)
+	todo.Updated = s.now()
+	todo.Version++
+	if err := store.Update(uid, todo); err != nil {
+		return nil, err
+	}
+	return store.Fetch(uid)
+}
+
+// deleteItem removes the item key refers to and its sub-tasks, or every
+// item when key is "*"
+func (s *ToDoStore) deleteItem(uid string, key string) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "*" {
+		// remove all items
+		for _, v := range userlist {
+			if err := store.Delete(uid, v.ItemId); err != nil {
+				return nil, err
+			}
+		}
+		return store.Fetch(uid)
+	}
+
+	idx := findItem(userlist, key)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+	children := descendants(userlist, userlist[idx].ItemId)
+	if len(children) > 0 && s.childPolicy == BlockOnChildren {
+		return nil, fmt.Errorf("%q has %d sub-tasks %w", userlist[idx].Item, len(children), ChildrenErr)
+	}
+	for _, v := range append(children, idx) {
+		if err := store.Delete(uid, userlist[v].ItemId); err != nil {
+			return nil, err
+		}
+	}
+	return store.Fetch(uid)
+}
+
+func getNewKey(userlist map[int]ToDoItem) int {
+	keyVal := 0
+	for idx, _ := range userlist {
+		if idx > keyVal {
+			keyVal = idx
+		}
+	}
+	return keyVal + 1
+}
+
+func itemExists(userlist map[int]ToDoItem, searchString string) int {
+	returnVal := -1
+	for idx, val := range userlist {
+		if val.Item == searchString {
+			returnVal = idx
+			break
+		}
+	}
+	return returnVal
+}
+
+// itemIndex returns the map key of the item with the given ItemId
+func itemIndex(userlist map[int]ToDoItem, itemId string) int {
+	for idx, val := range userlist {
+		if val.ItemId == itemId {
+			return idx
+		}
+	}
+	return -1
+}
+
+// findItem locates the item a mutation applies to. key can be the items
+// ItemId, its number in the SortedArray output or, as a fallback, its text.
+func findItem(userlist map[int]ToDoItem, key string) int {
+	if idx := itemIndex(userlist, key); idx != -1 {
+		return idx
+	}
+	if pos, err := strconv.Atoi(key); err == nil && pos > 0 && pos <= len(userlist) {
+		return orderedKeys(userlist)[pos-1]
+	}
+	return itemExists(userlist, key)
+}
+
+// SortedArray returns the items in manual order, numbered from 1. items
+// that already have a number, like those from a filtered fetch, keep it.
+func SortedArray(userlist map[int]ToDoItem) []ToDoItem {
+	returnVal := make([]ToDoItem, 0)
+	index := 1
+	for _, v := range orderedKeys(userlist) {
+		item := userlist[v]
+		if item.Id == 0 {
+			item.Id = index
+		}
+		returnVal = append(returnVal, item)
+		index += 1
+	}
+	return returnVal
+}
@@ -0,0 +1,272 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// every change to a list is recorded in the audit log as an event saying
+// who changed what and when, with the items before and after. events are
+// only ever appended, each one numbered after the last. the audit log of a
+// file or db backend is kept in a file next to its data and is written as
+// each change is made, the memory backend keeps its own in memory.
+
+// AuditEvent is one change to a users lists
+type AuditEvent struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Uid owns the lists that were changed, Actor made the change
+	Uid       string `json:"uid"`
+	Actor     string `json:"actor"`
+	Op        string `json:"op"`
+	RequestId string `json:"request_id,omitempty"`
+	// Detail is anything else about the change, like the backup restored
+	Detail  string        `json:"detail,omitempty"`
+	Changes []AuditChange `json:"changes,omitempty"`
+	// the named lists the change created and deleted
+	Created []string `json:"created,omitempty"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// AuditChange is an item before and after a change, Before is nil when it
+// was added and After when it was deleted. List is empty for the default
+// list.
+type AuditChange struct {
+	List   string    `json:"list,omitempty"`
+	Before *ToDoItem `json:"before,omitempty"`
+	After  *ToDoItem `json:"after,omitempty"`
+}
+
+// AuditFilter picks events out of the audit log, an empty field matches
+// every event
+type AuditFilter struct {
+	Uid   string
+	Actor string
+	Op    string
+	// events at or after From and before To
+	From time.Time
+	To   time.Time
+	// the latest Limit events, all of them when it is zero
+	Limit int
+}
+
+func (f AuditFilter) matches(event AuditEvent) bool {
+	switch {
+	case f.Uid != "" && event.Uid != f.Uid:
+		return false
+	case f.Actor != "" && event.Actor != f.Actor:
+		return false
+	case f.Op != "" && event.Op != f.Op:
+		return false
+	case !f.From.IsZero() && event.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !event.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// limit keeps the latest Limit events
+func (f AuditFilter) limit(events []AuditEvent) []AuditEvent {
+	if f.Limit > 0 && len(events) > f.Limit {
+		return events[len(events)-f.Limit:]
+	}
+	return events
+}
+
+// AuditLog keeps the audit trail. Append numbers the event and stores it,
+// Query returns the events matching filter, oldest first.
+type AuditLog interface {
+	Append(event AuditEvent) (AuditEvent, error)
+	Query(filter AuditFilter) ([]AuditEvent, error)
+}
+
+// MemoryAuditLog keeps events in memory
+type MemoryAuditLog struct {
+	mutex  sync.Mutex
+	events []AuditEvent
+}
+
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (m *MemoryAuditLog) Append(event AuditEvent) (AuditEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	event.Seq = int64(len(m.events)) + 1
+	m.events = append(m.events, event)
+	return event, nil
+}
+
+func (m *MemoryAuditLog) Query(filter AuditFilter) ([]AuditEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	events := make([]AuditEvent, 0)
+	for _, v := range m.events {
+		if filter.matches(v) {
+			events = append(events, v)
+		}
+	}
+	return filter.limit(events), nil
+}
+
+// FileAuditLog appends events to a file as lines of JSON
+type FileAuditLog struct {
+	mutex    sync.Mutex
+	filename string
+	// the number of the last event, -1 until the file has been read
+	seq int64
+}
+
+func NewFileAuditLog(filename string) *FileAuditLog {
+	return &FileAuditLog{filename: filename, seq: -1}
+}
+
+func (f *FileAuditLog) Append(event AuditEvent) (AuditEvent, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.seq < 0 {
+		seq := int64(0)
+		err := f.scan(func(v AuditEvent) {
+			seq = v.Seq
+		})
+		if err != nil {
+			return event, err
+		}
+		f.seq = seq
+	}
+	event.Seq = f.seq + 1
+	line, err := json.Marshal(event)
+	if err != nil {
+		return event, err
+	}
+	file, err := os.OpenFile(f.filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return event, err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return event, err
+	}
+	f.seq = event.Seq
+	return event, nil
+}
+
+func (f *FileAuditLog) Query(filter AuditFilter) ([]AuditEvent, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	events := make([]AuditEvent, 0)
+	err := f.scan(func(v AuditEvent) {
+		if filter.matches(v) {
+			events = append(events, v)
+		}
+	})
+	return filter.limit(events), err
+}
+
+// scan reads every event in the file, skipping a partly written last line
+func (f *FileAuditLog) scan(read func(AuditEvent)) error {
+	file, err := os.Open(f.filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	lineNo := 0
+	var torn error
+	for scanner.Scan() {
+		lineNo++
+		if torn != nil {
+			return fmt.Errorf("%s %w", f.filename, torn)
+		}
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			torn = fmt.Errorf("line %d: %w", lineNo, err)
+			continue
+		}
+		read(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s line %d: %w", f.filename, lineNo+1, err)
+	}
+	return nil
+}
+
+// WithAuditLog sets where the audit trail is kept, the default is a file
+// next to the data of a file or db backend and memory otherwise
+func WithAuditLog(log AuditLog) Option {
+	return func(s *ToDoStore) error {
+		s.audit = log
+		return nil
+	}
+}
+
+// auditLog returns the audit log in use, choosing the default for the
+// backend the first time it is needed
+func (s *ToDoStore) auditLog() AuditLog {
+	s.auditOnce.Do(func() {
+		if s.audit != nil {
+			return
+		}
+		if backend, ok := s.activeStore().(fileBacked); ok {
+			s.audit = NewFileAuditLog(backend.dataFile() + ".audit")
+		} else {
+			s.audit = NewMemoryAuditLog()
+		}
+	})
+	return s.audit
+}
+
+// auditChange adds what a change did to a users lists to the audit log.
+// the change has already been made so a failure is only logged.
+func (s *ToDoStore) auditChange(ctx context.Context, uid string, entry historyEntry, detail string) {
+	actor := Actor(ctx)
+	if actor == "" {
+		actor = uid
+	}
+	event := AuditEvent{Time: entry.Time, Uid: uid, Actor: actor, Op: entry.Op, RequestId: RequestId(ctx), Detail: detail}
+	for _, v := range entry.Items {
+		_, name := splitListKey(v.Key)
+		event.Changes = append(event.Changes, AuditChange{List: name, Before: v.Before, After: v.After})
+	}
+	for _, v := range entry.Created {
+		_, name := splitListKey(v)
+		event.Created = append(event.Created, name)
+	}
+	for _, v := range entry.Deleted {
+		_, name := splitListKey(v)
+		event.Deleted = append(event.Deleted, name)
+	}
+	if _, err := s.auditLog().Append(event); err != nil {
+		s.Logger.ErrorContext(ctx, fmt.Sprintf("error %v writing audit log", err))
+	}
+}
+
+// QueryAudit returns the events in the audit log matching filter, oldest
+// first
+func (s *ToDoStore) QueryAudit(filter AuditFilter) ([]AuditEvent, error) {
+	return s.auditLog().Query(filter)
+}
@@ -0,0 +1,172 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshots are written to a temporary file which is synced and then
+// renamed over the todo file, so a failed write never leaves a truncated
+// file behind. before the todo file is replaced its previous contents are
+// kept as a timestamped backup, the newest BackupCount of which are kept.
+
+// number of backups kept of the todo file, 0 turns backups off
+var BackupCount = 5
+
+const backupTimeFormat = "20060102T150405.000000000"
+
+var BackupNotFoundErr = fmt.Errorf("backup not found")
+
+func backupPattern(filename string) string {
+	return filename + ".*.bak"
+}
+
+// writeSnapshot keeps a backup of filename then atomically replaces it
+// with data
+func writeSnapshot(filename string, data []byte) error {
+	if err := backupSnapshot(filename, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// writeFileAtomic replaces filename with data so that a reader sees either
+// the old or new contents, never a partial write
+func writeFileAtomic(filename string, data []byte) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// backupSnapshot keeps a copy of filename unless it is empty or already
+// holds data
+func backupSnapshot(filename string, data []byte) error {
+	if BackupCount <= 0 {
+		return nil
+	}
+	current, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 || bytes.Equal(current, data) {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s.%s.bak", filename, time.Now().UTC().Format(backupTimeFormat))
+	if err := os.WriteFile(name, current, 0644); err != nil {
+		return err
+	}
+
+	backups, err := listBackups(filename)
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(BackupCount, len(backups)):] {
+		if err := os.Remove(old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir makes a rename in dir durable. not every platform can sync a
+// directory so failures are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+	d.Sync()
+	return nil
+}
+
+// listBackups returns the backups of filename, newest first
+func listBackups(filename string) ([]string, error) {
+	backups, err := filepath.Glob(backupPattern(filename))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// Backups returns the backups of the todo file, newest first
+func (f *FileStore) Backups() ([]string, error) {
+	return listBackups(f.filename)
+}
+
+// Restore replaces every list with the contents of backup and makes it the
+// current snapshot
+func (f *FileStore) Restore(backup string) error {
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, v := range backups {
+		if v == backup {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return BackupNotFoundErr
+	}
+
+	file, err := os.Open(backup)
+	if err != nil {
+		return err
+	}
+	lists, versions, _, err := f.readFile(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s %w", backup, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the journal only holds changes made after the backup. the lists go
+	// back but their versions carry on, so an old version can't match them.
+	for key, v := range f.versions {
+		versions[key] = max(versions[key], v) + 1
+	}
+	f.setLists(lists, versions)
+	return f.persist()
+}
@@ -0,0 +1,207 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// a batch is a list of changes, to one or more users lists, made all or
+// nothing. they are made in order and when one fails those already made are
+// rolled back, by putting every list the batch touched back how it was,
+// before the batch reports the failure. a batch that worked goes into each
+// users history as one change, so it is undone in one go. changes already
+// made are only ever rolled back by the store, a crash part way through a
+// batch leaves them in place.
+
+var UnknownOperationErr = fmt.Errorf("unknown operation")
+var EmptyBatchErr = fmt.Errorf("nothing in the batch")
+
+const (
+	BatchDone       = "done"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled back"
+	BatchSkipped    = "skipped"
+)
+
+// BatchOp is one change in a batch. Op names it the way the audit log does,
+// add, update, delete, done, reopen, tag, untag, move, repeat, due, remind,
+// reorder, priority, create list, rename list or delete list. Item and Value
+// are what KeyValue and AltValue are for the job of the same kind, the item
+// to change and what to change it to. an empty Uid or List is taken from
+// the batch. Version, when set, is the version of the item the change
+// expects.
+type BatchOp struct {
+	Op      string `json:"op"`
+	Uid     string `json:"uid,omitempty"`
+	List    string `json:"list,omitempty"`
+	Item    string `json:"item,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Version int64  `json:"version,omitempty"`
+}
+
+// BatchResult is what happened to one change in a batch
+type BatchResult struct {
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchJobType returns the job a batch operation is. an import takes the
+// items it adds rather than an Item and Value so it isn't one.
+func batchJobType(op string) (JobType, bool) {
+	for jobType, name := range undoableJobs {
+		if name == op && jobType != ImportData {
+			return jobType, true
+		}
+	}
+	return 0, false
+}
+
+// applyOp makes one change of a batch
+func (s *ToDoStore) applyOp(job DataStoreJob) error {
+	if err := s.checkVersion(job); err != nil {
+		return err
+	}
+	var err error
+	switch job.JobType {
+	case AddData:
+		_, err = s.addItem(job.key(), job.KeyValue, job.AltValue, "")
+	case UpdateData:
+		_, err = s.changeItem(job.key(), job.KeyValue, func(todo *ToDoItem) {
+			todo.Item = job.AltValue
+		})
+	case DeleteData:
+		_, err = s.deleteItem(job.key(), job.KeyValue)
+	case CompleteData, ReopenData:
+		_, err = s.setDone(job.key(), job.KeyValue, job.JobType == CompleteData)
+	case TagData, UntagData:
+		_, err = s.tagItem(job.key(), job.KeyValue, job.AltValue, job.JobType == TagData)
+	case CreateListData:
+		err = s.createList(job.Uid, job.List)
+	case RenameListData:
+		err = s.renameList(job.Uid, job.List, job.AltValue)
+	case DeleteListData:
+		err = s.deleteList(job.Uid, job.List)
+	case MoveData:
+		_, err = s.moveItem(job.key(), job.KeyValue, job.AltValue)
+	case RepeatData:
+		_, err = s.repeatItem(job.key(), job.KeyValue, job.AltValue)
+	case DueData:
+		_, err = s.dueItem(job.key(), job.KeyValue, job.AltValue)
+	case RemindData:
+		_, err = s.remindItem(job.key(), job.KeyValue, job.AltValue)
+	case ReorderData:
+		_, err = s.reorderItem(job.key(), job.KeyValue, job.AltValue)
+	case PriorityData:
+		_, err = s.priorityItem(job.key(), job.KeyValue, job.AltValue)
+	default:
+		err = UnknownOperationErr
+	}
+	return err
+}
+
+// batch makes the changes in ops all or nothing, filling in a missing uid
+// or list from uid and list
+func (s *ToDoStore) batch(ctx context.Context, uid string, list string, ops []BatchOp) ([]BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, EmptyBatchErr
+	}
+	results := make([]BatchResult, len(ops))
+	jobs := make([]DataStoreJob, len(ops))
+	uids := make([]string, 0)
+	for i, v := range ops {
+		results[i] = BatchResult{Op: v.Op, Status: BatchSkipped}
+		jobType, found := batchJobType(v.Op)
+		if !found {
+			err := fmt.Errorf("%q %w", v.Op, UnknownOperationErr)
+			results[i].Status, results[i].Error = BatchFailed, err.Error()
+			return results, fmt.Errorf("operation %d %w", i+1, err)
+		}
+		jobs[i] = DataStoreJob{Context: ctx, JobType: jobType, Uid: v.Uid, List: v.List, KeyValue: v.Item, AltValue: v.Value, Version: v.Version}
+		if jobs[i].Uid == "" {
+			jobs[i].Uid = uid
+		}
+		if jobs[i].List == "" {
+			jobs[i].List = list
+		}
+		if !slices.Contains(uids, jobs[i].Uid) {
+			uids = append(uids, jobs[i].Uid)
+		}
+	}
+
+	before := make(map[string]map[string]map[int]ToDoItem, len(uids))
+	for _, v := range uids {
+		lists, err := s.snapshot(v, nil)
+		if err != nil {
+			return results, err
+		}
+		before[v] = lists
+	}
+
+	for i, job := range jobs {
+		err := s.applyOp(job)
+		if err == nil {
+			results[i].Status = BatchDone
+			continue
+		}
+		results[i].Status, results[i].Error = BatchFailed, err.Error()
+		for j := range i {
+			results[j].Status = BatchRolledBack
+		}
+		err = fmt.Errorf("operation %d %w", i+1, err)
+		if rollbackErr := s.rollback(uids, before); rollbackErr != nil {
+			s.Logger.ErrorContext(ctx, fmt.Sprintf("error %v rolling back batch", rollbackErr))
+			err = errors.Join(err, rollbackErr)
+		}
+		return results, err
+	}
+
+	for _, v := range uids {
+		after, err := s.snapshot(v, nil)
+		if err != nil {
+			continue
+		}
+		s.changed(ctx, v, diff("batch", before[v], after, s.now()), fmt.Sprintf("%d operations", len(ops)))
+	}
+	return results, nil
+}
+
+// rollback puts the lists of uids back how they were in before
+func (s *ToDoStore) rollback(uids []string, before map[string]map[string]map[int]ToDoItem) error {
+	var err error
+	for _, v := range uids {
+		after, snapErr := s.snapshot(v, nil)
+		if snapErr != nil {
+			err = errors.Join(err, snapErr)
+			continue
+		}
+		err = errors.Join(err, s.applyEntry(diff("batch", before[v], after, s.now()), true))
+	}
+	return err
+}
+
+// BatchToDoList makes the changes in Batch all or nothing, returning what
+// happened to each of them in Results. Uid and List are used for changes
+// that don't name their own.
+func (s *ToDoStore) BatchToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Results, returnChannelData.Err = s.batch(dataJob.Context, dataJob.Uid, dataJob.List, dataJob.Batch)
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicBatch makes the changes in ops all or nothing and returns what
+// happened to each of them. uid, which can be a list key, is used for
+// changes that don't name their own.
+func (s *ToDoStore) BasicBatch(uid string, ops []BatchOp) ([]BatchResult, error) {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	owner, list := splitListKey(uid)
+	return s.batch(context.Background(), owner, list, ops)
+}
@@ -0,0 +1,75 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// when a change in a batch fails the ones before it are rolled back, and
+// the batch isn't in the history
+func TestBatchRollsBack(t *testing.T) {
+	s, err := New(WithStore(NewMemoryStore()), WithLogFile(os.DevNull))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: AddData, KeyValue: "milk"}); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+
+	ret, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: BatchData, Batch: []BatchOp{
+		{Op: "add", Item: "bread"},
+		{Op: "update", Item: "milk", Value: "oat milk"},
+		{Op: "create list", List: "shop"},
+		{Op: "add", List: "shop", Item: "eggs"},
+		{Op: "delete", Item: "cheese"},
+	}})
+	if !errors.Is(err, NotFoundErr) {
+		t.Errorf("Expected NotFoundErr got %v", err)
+	}
+	for i, v := range ret.Results {
+		status := BatchRolledBack
+		if i == len(ret.Results)-1 {
+			status = BatchFailed
+		}
+		if v.Status != status {
+			t.Errorf("Expected %s %s got %s", v.Op, status, v.Status)
+		}
+	}
+	// rolled back items get a new version, like undone ones, so only what
+	// they say is compared
+	lists, err := s.snapshot("tester", nil)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	if len(lists) != 1 || len(lists["tester"]) != 1 {
+		t.Errorf("Expected only milk got %v", lists)
+	}
+	fetchItem(t, s, "tester", "milk")
+	if ret, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UndoData}); err != nil || len(ret.List) != 0 {
+		t.Errorf("Expected the add of milk to be undone got %v %v", ret.List, err)
+	}
+}
+
+// an import isn't a batch operation, the batch fails before making any
+// change
+func TestBatchImportUnknown(t *testing.T) {
+	s, err := New(WithStore(NewMemoryStore()), WithLogFile(os.DevNull))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	defer s.Close()
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: "tester", JobType: BatchData, Batch: []BatchOp{
+		{Op: "add", Item: "bread"},
+		{Op: "import", Item: "milk"},
+	}})
+	if !errors.Is(err, UnknownOperationErr) {
+		t.Errorf("Expected UnknownOperationErr got %v", err)
+	}
+	if len(ret.Results) != 2 || ret.Results[0].Status != BatchSkipped || ret.Results[1].Status != BatchFailed {
+		t.Errorf("Expected the add skipped and the import failed got %v", ret.Results)
+	}
+}
@@ -0,0 +1,84 @@
+package ToDoListStore
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// the benchmarks compare the throughput of the data job queue with a single
+// worker, the way it used to run, against several workers.
+//
+//	go test -run - -bench ProcessDataJobs -args -users 64 -workers 8 -latency 1ms
+
+var usersFlag = flag.Int("users", 64, "number of users sending jobs at the same time")
+var workersFlag = flag.Int("workers", runtime.GOMAXPROCS(0), "number of workers to compare with a single one")
+var latencyFlag = flag.Duration("latency", time.Millisecond, "time the backend takes for each change, 0 for a plain memory store")
+
+// slowStore is a memory store that takes a while to make each change, like
+// a backend on the other end of a network
+type slowStore struct {
+	*MemoryStore
+	latency time.Duration
+}
+
+func (s slowStore) Add(uid string, item ToDoItem) error {
+	time.Sleep(s.latency)
+	return s.MemoryStore.Add(uid, item)
+}
+
+func (s slowStore) Delete(uid string, itemId string) error {
+	time.Sleep(s.latency)
+	return s.MemoryStore.Delete(uid, itemId)
+}
+
+// benchmarkProcessDataJobs adds and deletes an item b.N times for every
+// user, each user sending its jobs one after another from its own goroutine
+func benchmarkProcessDataJobs(b *testing.B, workers int) {
+	var store Store = NewMemoryStore()
+	if *latencyFlag > 0 {
+		store = slowStore{NewMemoryStore(), *latencyFlag}
+	}
+	s, err := New(WithStore(store), WithWorkers(workers), WithLogFile(os.DevNull))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for u := 0; u < *usersFlag; u++ {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			for i := 0; i < b.N; i++ {
+				item := fmt.Sprintf("item %d", i)
+				if _, err := s.Do(ctx, DataStoreJob{Uid: uid, JobType: AddData, KeyValue: item}); err != nil {
+					b.Error(err)
+					return
+				}
+				if _, err := s.Do(ctx, DataStoreJob{Uid: uid, JobType: DeleteData, KeyValue: item}); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(fmt.Sprintf("user%d", u))
+	}
+	wg.Wait()
+	jobs := b.N * *usersFlag * 2
+	b.ReportMetric(float64(jobs)/b.Elapsed().Seconds(), "jobs/s")
+}
+
+func BenchmarkProcessDataJobs_Single(b *testing.B) {
+	benchmarkProcessDataJobs(b, 1)
+}
+
+func BenchmarkProcessDataJobs_Partitioned(b *testing.B) {
+	benchmarkProcessDataJobs(b, *workersFlag)
+}
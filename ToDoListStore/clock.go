@@ -0,0 +1,115 @@
+package ToDoListStore
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the store the time. the reminder scheduler and recurring
+// items read it through the store so a FakeClock can stand in for the real
+// one in tests.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the clock the store reads the time from, the default is
+// the system clock
+func WithClock(clock Clock) Option {
+	return func(s *ToDoStore) error {
+		s.clock = clock
+		return nil
+	}
+}
+
+// now returns the time by the stores clock
+func (s *ToDoStore) now() time.Time {
+	return s.activeClock().Now()
+}
+
+func (s *ToDoStore) activeClock() Clock {
+	if s.clock == nil {
+		return realClock{}
+	}
+	return s.clock
+}
+
+// FakeClock is a Clock that only moves when it is told to. channels
+// returned by After fire when Advance or Set moves the clock past them.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at      time.Time
+	channel chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), channel})
+	return channel
+}
+
+// Advance moves the clock on by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	now := c.now.Add(d)
+	c.mutex.Unlock()
+	c.Set(now)
+}
+
+// Set moves the clock to now, firing the channels from After that are due
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+	waiting := c.waiters[:0]
+	for _, v := range c.waiters {
+		if v.at.After(now) {
+			waiting = append(waiting, v)
+		} else {
+			v.channel <- now
+		}
+	}
+	c.waiters = waiting
+}
+
+// Waiters returns how many channels from After have yet to fire, so a test
+// can wait for the scheduler to be waiting before moving the clock
+func (c *FakeClock) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,63 @@
+package ToDoListStore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// every time the store stamps an item with comes from its clock
+func TestItemTimesFromClock(t *testing.T) {
+	start := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s, err := New(WithStore(NewMemoryStore()), WithClock(clock), WithLogFile(os.DevNull))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	do := func(job DataStoreJob) {
+		t.Helper()
+		job.Uid = "tester"
+		if _, err := s.Do(ctx, job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	check := func(item string, created time.Time, updated time.Time, completed time.Time) {
+		t.Helper()
+		got := fetchItem(t, s, "tester", item)
+		if !got.Created.Equal(created) || !got.Updated.Equal(updated) || !got.Completed.Equal(completed) {
+			t.Errorf("Expected %s created %v updated %v completed %v got %v %v %v", item, created, updated, completed, got.Created, got.Updated, got.Completed)
+		}
+	}
+
+	do(DataStoreJob{JobType: AddData, KeyValue: "plan"})
+	check("plan", start, start, time.Time{})
+
+	edited := start.Add(time.Hour)
+	clock.Set(edited)
+	do(DataStoreJob{JobType: UpdateData, KeyValue: "plan", AltValue: "plan trip"})
+	do(DataStoreJob{JobType: AddData, KeyValue: "book", AltValue: "plan trip"})
+	check("plan trip", start, edited, time.Time{})
+	check("book", edited, edited, time.Time{})
+
+	done := edited.Add(time.Hour)
+	clock.Set(done)
+	do(DataStoreJob{JobType: CompleteData, KeyValue: "plan trip"})
+	check("plan trip", start, done, done)
+	check("book", edited, done, done)
+
+	// reopening a sub-task reopens its parent
+	reopened := done.Add(time.Hour)
+	clock.Set(reopened)
+	do(DataStoreJob{JobType: ReopenData, KeyValue: "book"})
+	check("plan trip", start, reopened, time.Time{})
+	check("book", edited, reopened, time.Time{})
+
+	moved := reopened.Add(time.Hour)
+	clock.Set(moved)
+	do(DataStoreJob{JobType: MoveData, KeyValue: "book"})
+	do(DataStoreJob{JobType: ReorderData, KeyValue: "book", AltValue: "first"})
+	check("book", edited, moved, time.Time{})
+}
@@ -0,0 +1,140 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// a job whose context has ended by the time it reaches the front of the
+// queue is skipped, and callers using Enqueue, Wait or Do stop waiting as
+// soon as their context ends. either way the error returned wraps
+// TimeoutErr or CanceledErr along with the context error.
+
+var TimeoutErr = fmt.Errorf("timed out")
+var CanceledErr = fmt.Errorf("canceled")
+
+// contextErr turns the error of an ended context into TimeoutErr or
+// CanceledErr
+func contextErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", TimeoutErr, err)
+	}
+	return fmt.Errorf("%w: %w", CanceledErr, err)
+}
+
+// jobDone returns the done channel of the jobs context, nil if it has none
+func jobDone(dataJob DataStoreJob) <-chan struct{} {
+	if dataJob.Context == nil {
+		return nil
+	}
+	return dataJob.Context.Done()
+}
+
+// reply sends the result of a job unless the caller has given up on it. a
+// list is sent with its version.
+func (s *ToDoStore) reply(dataJob DataStoreJob, data ReturnChannelData) {
+	if data.List != nil && data.Version == 0 {
+		data.Version, _ = s.listVersion(dataJob.key())
+	}
+	select {
+	case dataJob.ReturnChannel <- data:
+		return
+	default:
+	}
+	select {
+	case dataJob.ReturnChannel <- data:
+	case <-jobDone(dataJob):
+	}
+}
+
+// skipJob answers a job whose context ended while it was queued
+func (s *ToDoStore) skipJob(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	err := contextErr(dataJob.Context.Err())
+	s.Logger.WarnContext(dataJob.Context, fmt.Sprintf("skipping job %d for %s %v", dataJob.JobType, dataJob.Uid, err))
+	s.reply(dataJob, ReturnChannelData{Err: err})
+}
+
+// Enqueue adds job to the data job queue unless ctx ends first
+func (s *ToDoStore) Enqueue(ctx context.Context, dataJob DataStoreJob) error {
+	select {
+	case s.DataJobQueue <- dataJob:
+		return nil
+	case <-ctx.Done():
+		return contextErr(ctx.Err())
+	}
+}
+
+// Wait returns the result of a queued job unless ctx ends first. the error
+// is the jobs own error or the reason ctx ended.
+func Wait(ctx context.Context, dataJob DataStoreJob) (ReturnChannelData, error) {
+	select {
+	case returnVal := <-dataJob.ReturnChannel:
+		return returnVal, returnVal.Err
+	case <-ctx.Done():
+		return ReturnChannelData{}, contextErr(ctx.Err())
+	}
+}
+
+// Do queues a job and waits for its result, giving up when ctx ends. a
+// missing Context or ReturnChannel is filled in.
+func (s *ToDoStore) Do(ctx context.Context, dataJob DataStoreJob) (ReturnChannelData, error) {
+	if dataJob.Context == nil {
+		dataJob.Context = ctx
+	}
+	if dataJob.ReturnChannel == nil {
+		// buffered so the worker never waits on a caller that has gone
+		dataJob.ReturnChannel = make(chan ReturnChannelData, 1)
+	}
+	if err := s.Enqueue(ctx, dataJob); err != nil {
+		return ReturnChannelData{}, err
+	}
+	return Wait(ctx, dataJob)
+}
+
+// contextKey keys the values the store reads from a jobs context
+type contextKey string
+
+const (
+	requestIdKey contextKey = "request_id"
+	actorKey     contextKey = "actor"
+)
+
+// WithRequestId returns a copy of ctx carrying the id of the request it is
+// for, which is logged and recorded in the audit log
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// RequestId returns the id of the request ctx is for, empty if it has none
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIdKey).(string); ok {
+		return id
+	}
+	// the key frontends used before WithRequestId
+	id, _ := ctx.Value("X-Request-ID").(string)
+	return id
+}
+
+// WithActor returns a copy of ctx carrying who is making the request, when
+// it isn't the owner of the list being changed
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns who is making the request ctx is for, empty if it doesn't
+// say
+func Actor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if actor, ok := ctx.Value(actorKey).(string); ok {
+		return actor
+	}
+	actor, _ := ctx.Value("user_id").(string)
+	return actor
+}
@@ -0,0 +1,377 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+// DBStore keeps every list in a single embedded database file. the file is
+// a log of records, each one length prefixed and checksummed, that is
+// written and synced before a change is applied. loading replays the log
+// into memory and Persist compacts it down to one record per live item
+// and the version of each list.
+//
+//	file   = magic record*
+//	record = length:uint32 crc32:uint32 payload:[length]byte
+type DBStore struct {
+	*MemoryStore
+	filename string
+	file     *os.File
+}
+
+var dbMagic = []byte("TODODB\x00\x01")
+
+const dbRecordHeader = 8
+
+// largest payload accepted when reading the log
+const maxRecordLength = maxLineLength
+
+var CorruptDBErr = fmt.Errorf("corrupt database")
+
+// dbRecord is one change. an item record has Item or Deleted set, a list
+// record has Op set to one of the journal list operations. a version record
+// sets the version of the list once Persist has compacted its changes.
+type dbRecord struct {
+	Uid     string    `json:"uid"`
+	List    string    `json:"list,omitempty"`
+	Key     int       `json:"key,omitempty"`
+	Item    *ToDoItem `json:"item,omitempty"`
+	Deleted string    `json:"deleted,omitempty"`
+	Op      string    `json:"op,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Version int64     `json:"version,omitempty"`
+}
+
+func newDBRecord(key string) dbRecord {
+	uid, name := splitListKey(key)
+	return dbRecord{Uid: uid, List: name}
+}
+
+func NewDBStore(filename string) *DBStore {
+	return &DBStore{MemoryStore: NewMemoryStore(), filename: filename}
+}
+
+// Load opens the database, creating it if it doesn't exist, and reads every
+// record. a record cut short by a crash is dropped.
+func (d *DBStore) Load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file != nil {
+		d.file.Close()
+		d.file = nil
+	}
+
+	file, err := os.OpenFile(d.filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	d.setLists(make(map[string]baseToDoList), make(map[string]int64))
+	end, err := d.readRecords(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("%s %w", d.filename, err)
+	}
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	d.file = file
+	return nil
+}
+
+// readRecords applies every record in file and returns the offset after
+// the last good one
+func (d *DBStore) readRecords(file *os.File) (int64, error) {
+	magic := make([]byte, len(dbMagic))
+	n, err := io.ReadFull(file, magic)
+	if n == 0 && err == io.EOF {
+		if _, err := file.Write(dbMagic); err != nil {
+			return 0, err
+		}
+		return int64(len(dbMagic)), file.Sync()
+	}
+	if err != nil || !bytes.Equal(magic, dbMagic) {
+		return 0, UnsupportedFormatErr
+	}
+
+	offset := int64(len(dbMagic))
+	header := make([]byte, dbRecordHeader)
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, nil
+			}
+			return offset, err
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		sum := binary.BigEndian.Uint32(header[4:])
+		if length > maxRecordLength {
+			return offset, fmt.Errorf("offset %d: record length %d: %w", offset, length, CorruptDBErr)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(file, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				// the write was never acknowledged so it is safe to drop
+				d.log().Warn(fmt.Sprintf("ignoring partial record at offset %d of %s", offset, d.filename))
+				return offset, nil
+			}
+			return offset, err
+		}
+		if crc32.ChecksumIEEE(payload) != sum {
+			return offset, fmt.Errorf("offset %d: checksum mismatch: %w", offset, CorruptDBErr)
+		}
+		var rec dbRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return offset, fmt.Errorf("offset %d: %w", offset, err)
+		}
+		d.applyRecord(rec)
+		offset += dbRecordHeader + int64(length)
+	}
+}
+
+func (d *DBStore) applyRecord(rec dbRecord) {
+	key := ListKey(rec.Uid, rec.List)
+	switch rec.Op {
+	case journalCreate:
+		d.createList(key)
+		return
+	case journalRename:
+		d.renameList(key, ListKey(rec.Uid, rec.To))
+		return
+	case journalClear:
+		d.clearList(key)
+		return
+	case journalVersion:
+		d.versions[key] = rec.Version
+		return
+	}
+	if rec.Deleted != "" {
+		d.remove(key, rec.Deleted)
+		return
+	}
+	if rec.Item != nil {
+		d.put(key, rec.Key, *rec.Item)
+	}
+}
+
+func encodeRecord(rec dbRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, dbRecordHeader, dbRecordHeader+len(payload))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	return append(buf, payload...), nil
+}
+
+func (d *DBStore) writeRecord(rec dbRecord) error {
+	if d.file == nil {
+		return nil
+	}
+	buf, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := d.file.Write(buf); err != nil {
+		return err
+	}
+	return d.file.Sync()
+}
+
+func (d *DBStore) Add(uid string, item ToDoItem) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	item.Id = 0
+	idx := getNewKey(d.lists[uid])
+	rec := newDBRecord(uid)
+	rec.Key, rec.Item = idx, &item
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	d.put(uid, idx, item)
+	return nil
+}
+
+func (d *DBStore) Update(uid string, item ToDoItem) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	idx := itemIndex(d.lists[uid], item.ItemId)
+	if idx == -1 {
+		return NotFoundErr
+	}
+	item.Id = 0
+	rec := newDBRecord(uid)
+	rec.Key, rec.Item = idx, &item
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	d.put(uid, idx, item)
+	return nil
+}
+
+func (d *DBStore) Put(uid string, idx int, item ToDoItem) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	item.Id = 0
+	rec := newDBRecord(uid)
+	rec.Key, rec.Item = d.placeItem(uid, idx, item.ItemId), &item
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	d.put(uid, rec.Key, item)
+	return nil
+}
+
+func (d *DBStore) Delete(uid string, itemId string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if itemIndex(d.lists[uid], itemId) == -1 {
+		return NotFoundErr
+	}
+	rec := newDBRecord(uid)
+	rec.Deleted = itemId
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	return d.remove(uid, itemId)
+}
+
+// Persist compacts the database so it only holds the live items
+func (d *DBStore) Persist() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out bytes.Buffer
+	out.Write(dbMagic)
+
+	listKeys := make([]string, 0, len(d.lists))
+	for key := range d.lists {
+		listKeys = append(listKeys, key)
+	}
+	sort.Strings(listKeys)
+	for _, key := range listKeys {
+		if isNamedList(key) {
+			rec := newDBRecord(key)
+			rec.Op = journalCreate
+			buf, err := encodeRecord(rec)
+			if err != nil {
+				return err
+			}
+			out.Write(buf)
+		}
+		keys := make([]int, 0, len(d.lists[key]))
+		for idx := range d.lists[key] {
+			keys = append(keys, idx)
+		}
+		sort.Ints(keys)
+		for _, idx := range keys {
+			item := d.lists[key][idx]
+			rec := newDBRecord(key)
+			rec.Key, rec.Item = idx, &item
+			buf, err := encodeRecord(rec)
+			if err != nil {
+				return err
+			}
+			out.Write(buf)
+		}
+		// replaying the records above doesn't count the changes that led
+		// to them
+		rec := newDBRecord(key)
+		rec.Op, rec.Version = journalVersion, d.versions[key]
+		buf, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		out.Write(buf)
+	}
+
+	if err := writeFileAtomic(d.filename, out.Bytes()); err != nil {
+		return err
+	}
+	if d.file == nil {
+		return nil
+	}
+	// the old handle points at the file that was just replaced
+	d.file.Close()
+	file, err := os.OpenFile(d.filename, os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		d.file = nil
+		return fmt.Errorf("reopening %s %w", d.filename, err)
+	}
+	d.file = file
+	return nil
+}
+
+func (d *DBStore) CreateList(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, found := d.lists[key]; found {
+		return d.createList(key)
+	}
+	rec := newDBRecord(key)
+	rec.Op = journalCreate
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	return d.createList(key)
+}
+
+func (d *DBStore) RenameList(key string, newKey string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkRename(key, newKey); err != nil {
+		return err
+	}
+	rec := newDBRecord(key)
+	rec.Op = journalRename
+	_, rec.To = splitListKey(newKey)
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	return d.renameList(key, newKey)
+}
+
+func (d *DBStore) DeleteList(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, found := d.lists[key]; !found {
+		return d.deleteList(key)
+	}
+	rec := newDBRecord(key)
+	rec.Op = journalClear
+	if err := d.writeRecord(rec); err != nil {
+		return err
+	}
+	return d.deleteList(key)
+}
+
+func (d *DBStore) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
@@ -0,0 +1,316 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// the package level queues, logger and functions all belong to Default, a
+// store whose workers are started by the caller with ProcessDataJobs and
+// ProcessLoggerJobs. use New for a store of your own.
+
+var Logger, defaultLogFile = newFileLogger(fmt.Sprintf("todo-%d.log", time.Now().UnixMicro()))
+
+var DataJobQueue = make(chan DataStoreJob, 1000)
+var LoggerJobQueue = make(chan LoggerJob, 1000)
+
+var Default = &ToDoStore{
+	DataJobQueue:   DataJobQueue,
+	LoggerJobQueue: LoggerJobQueue,
+	Logger:         Logger,
+	logFile:        defaultLogFile,
+}
+
+func Init() {
+	slog.SetDefault(Logger)
+}
+
+func ProcessDataJobs() {
+	Default.ProcessDataJobs()
+}
+
+func ProcessLoggerJobs() {
+	Default.ProcessLoggerJobs()
+}
+
+func ProcessReminders() {
+	Default.ProcessReminders()
+}
+
+func CheckReminders(ctx context.Context) []ReminderEvent {
+	return Default.CheckReminders(ctx)
+}
+
+func AddNotifier(notifier Notifier) {
+	Default.AddNotifier(notifier)
+}
+
+func Enqueue(ctx context.Context, dataJob DataStoreJob) error {
+	return Default.Enqueue(ctx, dataJob)
+}
+
+func Do(ctx context.Context, dataJob DataStoreJob) (ReturnChannelData, error) {
+	return Default.Do(ctx, dataJob)
+}
+
+func UseStore(store Store) {
+	Default.UseStore(store)
+}
+
+func SetChildPolicy(policy ChildPolicy) {
+	Default.SetChildPolicy(policy)
+}
+
+func ListBackups() ([]string, error) {
+	return Default.ListBackups()
+}
+
+func GetUserList(uid string) map[int]ToDoItem {
+	return Default.GetUserList(uid)
+}
+
+func GetVersionedList(uid string) (map[int]ToDoItem, int64) {
+	return Default.GetVersionedList(uid)
+}
+
+func LoadToDoList(dataJob DataStoreJob) {
+	Default.LoadToDoList(dataJob)
+}
+
+func FetchToDoList(dataJob DataStoreJob) {
+	Default.FetchToDoList(dataJob)
+}
+
+func AddToDoItem(dataJob DataStoreJob) {
+	Default.AddToDoItem(dataJob)
+}
+
+func UpdateToDoItem(dataJob DataStoreJob) {
+	Default.UpdateToDoItem(dataJob)
+}
+
+func DeleteToDoItem(dataJob DataStoreJob) {
+	Default.DeleteToDoItem(dataJob)
+}
+
+func CompleteToDoItem(dataJob DataStoreJob) {
+	Default.CompleteToDoItem(dataJob)
+}
+
+func ReopenToDoItem(dataJob DataStoreJob) {
+	Default.ReopenToDoItem(dataJob)
+}
+
+func PersistEntries(dataJob DataStoreJob) {
+	Default.PersistEntries(dataJob)
+}
+
+func RestoreToDoList(dataJob DataStoreJob) {
+	Default.RestoreToDoList(dataJob)
+}
+
+func TagToDoItem(dataJob DataStoreJob) {
+	Default.TagToDoItem(dataJob)
+}
+
+func UntagToDoItem(dataJob DataStoreJob) {
+	Default.UntagToDoItem(dataJob)
+}
+
+func FilterToDoList(dataJob DataStoreJob) {
+	Default.FilterToDoList(dataJob)
+}
+
+func CreateList(dataJob DataStoreJob) {
+	Default.CreateList(dataJob)
+}
+
+func RenameList(dataJob DataStoreJob) {
+	Default.RenameList(dataJob)
+}
+
+func DeleteList(dataJob DataStoreJob) {
+	Default.DeleteList(dataJob)
+}
+
+func FetchLists(dataJob DataStoreJob) {
+	Default.FetchLists(dataJob)
+}
+
+func MoveToDoItem(dataJob DataStoreJob) {
+	Default.MoveToDoItem(dataJob)
+}
+
+func RepeatToDoItem(dataJob DataStoreJob) {
+	Default.RepeatToDoItem(dataJob)
+}
+
+func DueToDoItem(dataJob DataStoreJob) {
+	Default.DueToDoItem(dataJob)
+}
+
+func ReorderToDoItem(dataJob DataStoreJob) {
+	Default.ReorderToDoItem(dataJob)
+}
+
+func PriorityToDoItem(dataJob DataStoreJob) {
+	Default.PriorityToDoItem(dataJob)
+}
+
+func ImportToDoList(dataJob DataStoreJob) {
+	Default.ImportToDoList(dataJob)
+}
+
+func ExportToDoList(dataJob DataStoreJob) {
+	Default.ExportToDoList(dataJob)
+}
+
+func RemindToDoItem(dataJob DataStoreJob) {
+	Default.RemindToDoItem(dataJob)
+}
+
+func SearchToDoList(dataJob DataStoreJob) {
+	Default.SearchToDoList(dataJob)
+}
+
+func UndoToDoList(dataJob DataStoreJob) {
+	Default.UndoToDoList(dataJob)
+}
+
+func RedoToDoList(dataJob DataStoreJob) {
+	Default.RedoToDoList(dataJob)
+}
+
+func BatchToDoList(dataJob DataStoreJob) {
+	Default.BatchToDoList(dataJob)
+}
+
+func BasicLoadToDoList() error {
+	return Default.BasicLoadToDoList()
+}
+
+func BasicPersistEntries() error {
+	return Default.BasicPersistEntries()
+}
+
+func BasicAddToDoItem(uid string, item string) error {
+	return Default.BasicAddToDoItem(uid, item)
+}
+
+func BasicUpdateToDoItem(uid string, item string, replacewith string) error {
+	return Default.BasicUpdateToDoItem(uid, item, replacewith)
+}
+
+func BasicDeleteToDoItem(uid string, item string) error {
+	return Default.BasicDeleteToDoItem(uid, item)
+}
+
+func BasicUpdateToDoItemVersion(uid string, item string, replacewith string, listVersion int64, version int64) error {
+	return Default.BasicUpdateToDoItemVersion(uid, item, replacewith, listVersion, version)
+}
+
+func BasicDeleteToDoItemVersion(uid string, item string, listVersion int64, version int64) error {
+	return Default.BasicDeleteToDoItemVersion(uid, item, listVersion, version)
+}
+
+func BasicCompleteToDoItem(uid string, item string) error {
+	return Default.BasicCompleteToDoItem(uid, item)
+}
+
+func BasicReopenToDoItem(uid string, item string) error {
+	return Default.BasicReopenToDoItem(uid, item)
+}
+
+func BasicTagToDoItem(uid string, item string, tag string) error {
+	return Default.BasicTagToDoItem(uid, item, tag)
+}
+
+func BasicUntagToDoItem(uid string, item string, tag string) error {
+	return Default.BasicUntagToDoItem(uid, item, tag)
+}
+
+func BasicFilterToDoList(uid string, tag string) (map[int]ToDoItem, error) {
+	return Default.BasicFilterToDoList(uid, tag)
+}
+
+func BasicCreateList(uid string, name string) error {
+	return Default.BasicCreateList(uid, name)
+}
+
+func BasicRenameList(uid string, name string, newName string) error {
+	return Default.BasicRenameList(uid, name, newName)
+}
+
+func BasicDeleteList(uid string, name string) error {
+	return Default.BasicDeleteList(uid, name)
+}
+
+func BasicLists(uid string) ([]string, error) {
+	return Default.BasicLists(uid)
+}
+
+func BasicAddSubTask(uid string, parent string, item string) error {
+	return Default.BasicAddSubTask(uid, parent, item)
+}
+
+func BasicMoveToDoItem(uid string, item string, parent string) error {
+	return Default.BasicMoveToDoItem(uid, item, parent)
+}
+
+func BasicAddRecurringToDoItem(uid string, parent string, item string, rule string) error {
+	return Default.BasicAddRecurringToDoItem(uid, parent, item, rule)
+}
+
+func BasicRepeatToDoItem(uid string, item string, rule string) error {
+	return Default.BasicRepeatToDoItem(uid, item, rule)
+}
+
+func BasicDueToDoItem(uid string, item string, due string) error {
+	return Default.BasicDueToDoItem(uid, item, due)
+}
+
+func BasicReorderToDoItem(uid string, item string, position string) error {
+	return Default.BasicReorderToDoItem(uid, item, position)
+}
+
+func BasicPriorityToDoItem(uid string, item string, priority string) error {
+	return Default.BasicPriorityToDoItem(uid, item, priority)
+}
+
+func BasicImport(uid string, list string, items []ExportItem, dryRun bool) (ImportResult, error) {
+	return Default.BasicImport(uid, list, items, dryRun)
+}
+
+func BasicExport(uid string, list string) ([]ExportItem, error) {
+	return Default.BasicExport(uid, list)
+}
+
+func BasicRemindToDoItem(uid string, item string, reminders string) error {
+	return Default.BasicRemindToDoItem(uid, item, reminders)
+}
+
+func BasicSearchToDoList(uid string, query string) ([]SearchResult, error) {
+	return Default.BasicSearchToDoList(uid, query)
+}
+
+func BasicUndo(uid string) (string, error) {
+	return Default.BasicUndo(uid)
+}
+
+func BasicRedo(uid string) (string, error) {
+	return Default.BasicRedo(uid)
+}
+
+func BasicBatch(uid string, ops []BatchOp) ([]BatchResult, error) {
+	return Default.BasicBatch(uid, ops)
+}
+
+func QueryAudit(filter AuditFilter) ([]AuditEvent, error) {
+	return Default.QueryAudit(filter)
+}
+
+func Subscribe(uid string, filter ChangeFilter, options ...SubscribeOption) *Subscription {
+	return Default.Subscribe(uid, filter, options...)
+}
@@ -0,0 +1,533 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// a users lists are exported, and items imported, as
+//
+//	json      an array of items, each with the list it is on
+//	csv       a header row naming the columns, see csvColumns, then a row
+//	          for each item
+//	markdown  a - [ ] checklist with a heading for each named list and
+//	          sub-tasks indented under their parent
+//	todotxt   see todotxt.go, with the list in a list extension
+//	ical      an iCalendar VTODO for each item, see ical.go
+//
+// an import adds items to the list they name, or to the one it is made on
+// when they don't, creating the lists that don't exist. items with the id
+// or the text of one already on the list are skipped as duplicates, so
+// importing an export again adds nothing. a dry run reports what an import
+// would add and skip without changing anything.
+
+var UnknownFormatErr = fmt.Errorf("unknown format")
+var InvalidImportErr = fmt.Errorf("invalid import")
+
+const (
+	JSONFormat     = "json"
+	CSVFormat      = "csv"
+	MarkdownFormat = "markdown"
+	ToDoTxtFormat  = "todotxt"
+	ICalFormat     = "ical"
+)
+
+// AllLists, as the list to export, exports every list the user has
+const AllLists = "*"
+
+// ExportItem is an item along with the name of the list it is on, empty
+// for the default list
+type ExportItem struct {
+	List string `json:"list,omitempty"`
+	ToDoItem
+}
+
+// ImportResult is what an import added and what it skipped as duplicates
+type ImportResult struct {
+	Added   []ExportItem `json:"added"`
+	Skipped []ExportItem `json:"skipped"`
+}
+
+// ParseFormat returns the format named by format, md is short for
+// markdown, todo.txt for todotxt and ics or icalendar for ical
+func ParseFormat(format string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case JSONFormat, CSVFormat, MarkdownFormat, ToDoTxtFormat, ICalFormat:
+		return f, nil
+	case "md":
+		return MarkdownFormat, nil
+	case "todo.txt", "txt":
+		return ToDoTxtFormat, nil
+	case "ics", "icalendar":
+		return ICalFormat, nil
+	}
+	return "", fmt.Errorf("%q %w", format, UnknownFormatErr)
+}
+
+// WriteItems writes items in format, in the order given
+func WriteItems(w io.Writer, format string, items []ExportItem) error {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case JSONFormat:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case CSVFormat:
+		return writeCSV(w, items)
+	case MarkdownFormat:
+		return writeMarkdown(w, items)
+	case ICalFormat:
+		return writeICal(w, items)
+	}
+	todos := make([]ToDoItem, 0, len(items))
+	for _, v := range items {
+		if v.List != "" {
+			v.Extensions = maps.Clone(v.Extensions)
+			if v.Extensions == nil {
+				v.Extensions = make(map[string]string)
+			}
+			v.Extensions[todoTxtList] = v.List
+		}
+		todos = append(todos, v.ToDoItem)
+	}
+	return WriteToDoTxt(w, todos)
+}
+
+// ReadItems reads the items in format from r
+func ReadItems(r io.Reader, format string) ([]ExportItem, error) {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case JSONFormat:
+		items := make([]ExportItem, 0)
+		if err := json.NewDecoder(r).Decode(&items); err != nil {
+			return nil, fmt.Errorf("%v %w", err, InvalidImportErr)
+		}
+		return items, nil
+	case CSVFormat:
+		return readCSV(r)
+	case MarkdownFormat:
+		return readMarkdown(r)
+	case ICalFormat:
+		return readICal(r)
+	}
+	todos, err := ReadToDoTxt(r)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ExportItem, 0, len(todos))
+	for _, v := range todos {
+		name := v.Extensions[todoTxtList]
+		delete(v.Extensions, todoTxtList)
+		delete(v.Extensions, todoTxtUid)
+		if len(v.Extensions) == 0 {
+			v.Extensions = nil
+		}
+		items = append(items, ExportItem{name, v})
+	}
+	return items, nil
+}
+
+// the columns of a csv export. an import needs the item column, the others
+// can be left out and come in any order.
+var csvColumns = []string{"list", "id", "item", "done", "priority", "due", "tags", "parent", "notes", "repeat", "created", "completed"}
+
+// the layout of due dates in csv, one a spreadsheet and ParseDue can read
+const csvDue = "2006-01-02 15:04"
+
+func csvTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(layout)
+}
+
+func writeCSV(w io.Writer, items []ExportItem) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, v := range items {
+		done := ""
+		if v.Done {
+			done = "x"
+		}
+		row := []string{v.List, v.ItemId, v.Item, done, v.Priority, csvTime(v.Due, csvDue), strings.Join(v.Tags, " "), v.Parent, v.Notes, v.Repeat, csvTime(v.Created, time.RFC3339), csvTime(v.Completed, time.RFC3339)}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func readCSV(r io.Reader) ([]ExportItem, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+	header, err := in.Read()
+	if err == io.EOF {
+		return make([]ExportItem, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v %w", err, InvalidImportErr)
+	}
+	columns := make(map[string]int, len(header))
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	if _, found := columns["item"]; !found {
+		return nil, fmt.Errorf("no item column %w", InvalidImportErr)
+	}
+
+	items := make([]ExportItem, 0)
+	for row := 2; ; row++ {
+		record, err := in.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v %w", err, InvalidImportErr)
+		}
+		field := func(name string) string {
+			if i, found := columns[name]; found && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item, err := csvItem(field)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		items = append(items, item)
+	}
+}
+
+// csvItem makes an item from the fields of a csv row
+func csvItem(field func(string) string) (ExportItem, error) {
+	item := ExportItem{field("list"), NewToDoItem(field("item"))}
+	if id := field("id"); id != "" {
+		item.ItemId = id
+	}
+	switch strings.ToLower(field("done")) {
+	case "", "false", "no", "0":
+	default:
+		item.Done = true
+	}
+	var err error
+	if item.Priority, err = ParsePriority(field("priority")); err != nil {
+		return item, err
+	}
+	if due := field("due"); due != "" {
+		if item.Due, err = ParseDue(due, time.Now()); err != nil {
+			return item, err
+		}
+	}
+	for _, v := range strings.Fields(field("tags")) {
+		tag, err := NormaliseTag(v)
+		if err != nil {
+			return item, err
+		}
+		item.addTag(tag)
+	}
+	item.Parent, item.Notes = field("parent"), field("notes")
+	if repeat := field("repeat"); repeat != "" {
+		if _, err := ParseRecurrence(repeat, time.Now()); err != nil {
+			return item, err
+		}
+		item.Repeat = repeat
+	}
+	for _, v := range []struct {
+		name string
+		to   *time.Time
+	}{{"created", &item.Created}, {"completed", &item.Completed}} {
+		if value := field(v.name); value != "" {
+			if *v.to, err = ParseDue(value, time.Now()); err != nil {
+				return item, fmt.Errorf("%s %w", v.name, err)
+			}
+		}
+	}
+	item.Updated = item.Created
+	return item, nil
+}
+
+func writeMarkdown(w io.Writer, items []ExportItem) error {
+	var write func(nodes []ToDoNode, depth int) error
+	write = func(nodes []ToDoNode, depth int) error {
+		for _, v := range nodes {
+			box := "[ ]"
+			if v.Done {
+				box = "[x]"
+			}
+			if _, err := fmt.Fprintf(w, "%s- %s %s\n", strings.Repeat("  ", depth), box, strings.Join(strings.Fields(v.Item), " ")); err != nil {
+				return err
+			}
+			if err := write(v.Children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for start := 0; start < len(items); {
+		name := items[start].List
+		end := start
+		todos := make([]ToDoItem, 0)
+		for ; end < len(items) && items[end].List == name; end++ {
+			todos = append(todos, items[end].ToDoItem)
+		}
+		if name != "" {
+			if start > 0 {
+				fmt.Fprintln(w)
+			}
+			if _, err := fmt.Fprintf(w, "## %s\n\n", name); err != nil {
+				return err
+			}
+		}
+		if err := write(Tree(todos), 0); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+var markdownHeading = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+var markdownItem = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+(?:\[([ xX])\]\s+)?(.*)$`)
+
+// readMarkdown reads the items of a markdown list, checklist or not. a
+// heading starts the items of the list it names and an item indented
+// under another is a sub-task of it. other lines are skipped.
+func readMarkdown(r io.Reader) ([]ExportItem, error) {
+	type parent struct {
+		indent int
+		id     string
+	}
+	items := make([]ExportItem, 0)
+	name := ""
+	parents := make([]parent, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		line := strings.ReplaceAll(scanner.Text(), "\t", "    ")
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			name, parents = m[1], parents[:0]
+			if strings.EqualFold(name, DefaultList) {
+				name = ""
+			}
+			continue
+		}
+		m := markdownItem.FindStringSubmatch(line)
+		if m == nil || strings.TrimSpace(m[3]) == "" {
+			continue
+		}
+		item := ExportItem{name, NewToDoItem(strings.TrimSpace(m[3]))}
+		item.Done = m[2] == "x" || m[2] == "X"
+		indent := len(m[1])
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		if len(parents) > 0 {
+			item.Parent = parents[len(parents)-1].id
+		}
+		parents = append(parents, parent{indent, item.ItemId})
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// exportItems returns the items on a users list, or on every list they
+// have for AllLists, in manual order
+func (s *ToDoStore) exportItems(uid string, name string) ([]ExportItem, error) {
+	store := s.activeStore()
+	names := []string{name}
+	if name == AllLists {
+		lists, err := store.Lists(uid)
+		if err != nil {
+			return nil, err
+		}
+		names = append([]string{""}, lists...)
+	}
+	items := make([]ExportItem, 0)
+	for _, v := range names {
+		userlist, err := store.Fetch(ListKey(uid, v))
+		if err != nil {
+			return nil, err
+		}
+		if v == DefaultList {
+			v = ""
+		}
+		for _, item := range SortedArray(userlist) {
+			items = append(items, ExportItem{v, item})
+		}
+	}
+	return items, nil
+}
+
+// duplicateKey is what an items text is compared by to find duplicates
+func duplicateKey(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// importItems adds items to the lists uid owns, those that don't name one
+// to target. nothing is changed for a dry run.
+func (s *ToDoStore) importItems(uid string, target string, items []ExportItem, dryRun bool) (ImportResult, error) {
+	result := ImportResult{Added: make([]ExportItem, 0), Skipped: make([]ExportItem, 0)}
+	store := s.activeStore()
+	// what is on each list the import adds to, by key
+	type importList struct {
+		ids     map[string]bool
+		texts   map[string]string
+		rank    int64
+		missing bool
+	}
+	lists := make(map[string]*importList)
+	// the ids of skipped items that their sub-tasks go under instead
+	moved := make(map[string]string)
+	for i, v := range items {
+		if v.List == "" {
+			v.List = target
+		}
+		if v.List == DefaultList {
+			v.List = ""
+		}
+		if v.List != "" {
+			name, err := NormaliseListName(v.List)
+			if err != nil {
+				return result, fmt.Errorf("item %d %w", i+1, err)
+			}
+			v.List = name
+		}
+		key := ListKey(uid, v.List)
+		l, found := lists[key]
+		if !found {
+			userlist, err := store.Fetch(key)
+			if err != nil && !errors.Is(err, NotFoundErr) {
+				return result, err
+			}
+			l = &importList{ids: make(map[string]bool), texts: make(map[string]string), rank: lastRank(userlist), missing: err != nil}
+			for _, item := range userlist {
+				l.ids[item.ItemId] = true
+				l.texts[duplicateKey(item.Item)] = item.ItemId
+			}
+			lists[key] = l
+		}
+
+		text := duplicateKey(v.Item)
+		if text == "" || l.ids[v.ItemId] {
+			result.Skipped = append(result.Skipped, v)
+			continue
+		}
+		if id, dup := l.texts[text]; dup {
+			moved[v.ItemId] = id
+			result.Skipped = append(result.Skipped, v)
+			continue
+		}
+		if v.ItemId == "" {
+			v.ItemId = uuid.Must(uuid.NewV7()).String()
+		}
+		l.ids[v.ItemId], l.texts[text] = true, v.ItemId
+		v.Id, v.Version, v.Rank = 0, 1, l.rank
+		l.rank += rankGap
+		result.Added = append(result.Added, v)
+	}
+	for i, v := range result.Added {
+		if id, found := moved[v.Parent]; found {
+			result.Added[i].Parent = id
+		}
+	}
+	if dryRun || len(result.Added) == 0 {
+		return result, nil
+	}
+
+	before, err := s.snapshot(uid, nil)
+	if err != nil {
+		return result, err
+	}
+	for _, v := range result.Added {
+		key := ListKey(uid, v.List)
+		if l := lists[key]; l.missing {
+			err = s.createList(uid, v.List)
+			l.missing = false
+		}
+		if err == nil {
+			err = store.Add(key, v.ToDoItem)
+		}
+		if err != nil {
+			if rollbackErr := s.rollback([]string{uid}, map[string]map[string]map[int]ToDoItem{uid: before}); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// ExportToDoList returns in Items the items on the list, or those on every
+// list the user has when List is AllLists
+func (s *ToDoStore) ExportToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Items, returnChannelData.Err = s.exportItems(dataJob.Uid, dataJob.List)
+	s.reply(dataJob, returnChannelData)
+}
+
+// ImportToDoList adds the items in Items to the list, or to the lists they
+// name, and returns what it added and skipped in Imported. with DryRun set
+// it only says what it would do.
+func (s *ToDoStore) ImportToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Imported, returnChannelData.Err = s.importItems(dataJob.Uid, dataJob.List, dataJob.Items, dataJob.DryRun)
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicExport returns the items on a users list, or on every list they
+// have when list is AllLists
+func (s *ToDoStore) BasicExport(uid string, list string) ([]ExportItem, error) {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	return s.exportItems(uid, list)
+}
+
+// BasicImport adds items to a users list, or to the lists they name, and
+// returns what it added and skipped. a dry run only says what it would do.
+func (s *ToDoStore) BasicImport(uid string, list string, items []ExportItem, dryRun bool) (ImportResult, error) {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	var result ImportResult
+	err := s.recordChange(context.Background(), uid, "import", func() error {
+		var err error
+		result, err = s.importItems(uid, list, items, dryRun)
+		return err
+	})
+	return result, err
+}
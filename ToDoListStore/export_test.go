@@ -0,0 +1,161 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// newExportStore returns a store where the tester has a sub-task, a tagged
+// item with a priority and a named list
+func newExportStore(t *testing.T) *ToDoStore {
+	t.Helper()
+	s, err := New(WithStore(NewMemoryStore()), WithLogFile(os.DevNull))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	for _, job := range []DataStoreJob{
+		{JobType: AddData, KeyValue: "plan trip"},
+		{JobType: AddData, KeyValue: "book", AltValue: "plan trip"},
+		{JobType: CompleteData, KeyValue: "book"},
+		{JobType: AddData, KeyValue: "milk"},
+		{JobType: TagData, KeyValue: "milk", AltValue: "dairy"},
+		{JobType: PriorityData, KeyValue: "milk", AltValue: "A"},
+		{JobType: CreateListData, List: "shop"},
+		{JobType: AddData, List: "shop", KeyValue: "eggs"},
+	} {
+		job.Uid = "tester"
+		if _, err := s.Do(context.Background(), job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	return s
+}
+
+func exportAll(t *testing.T, s *ToDoStore, uid string) []ExportItem {
+	t.Helper()
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: uid, JobType: ExportData, List: AllLists})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	return ret.Items
+}
+
+func importItems(t *testing.T, s *ToDoStore, uid string, items []ExportItem, dryRun bool) ImportResult {
+	t.Helper()
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: uid, JobType: ImportData, Items: items, DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	return ret.Imported
+}
+
+// exported describes each item by what every format keeps, its list, text,
+// whether it is done and the text of its parent, and with full by the rest
+// json and csv keep too
+func exported(items []ExportItem, full bool) []string {
+	texts := make(map[string]string, len(items))
+	for _, v := range items {
+		texts[v.ItemId] = v.Item
+	}
+	out := make([]string, 0, len(items))
+	for _, v := range items {
+		about := fmt.Sprintf("%s/%s done %v under %q", v.List, v.Item, v.Done, texts[v.Parent])
+		if full {
+			about += fmt.Sprintf(" %s %v %s", v.ItemId, v.Tags, v.Priority)
+		}
+		out = append(out, about)
+	}
+	return out
+}
+
+// items exported in each format import as they were
+func TestExportRoundTrip(t *testing.T) {
+	s := newExportStore(t)
+	items := exportAll(t, s, "tester")
+	for _, format := range []string{JSONFormat, CSVFormat, MarkdownFormat} {
+		var buf bytes.Buffer
+		if err := WriteItems(&buf, format, items); err != nil {
+			t.Fatalf("%s: Expected nil got %v", format, err)
+		}
+		read, err := ReadItems(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: Expected nil got %v", format, err)
+		}
+		uid := "copy-" + format
+		if result := importItems(t, s, uid, read, false); len(result.Added) != len(items) {
+			t.Errorf("%s: Expected %d added got %v", format, len(items), result)
+		}
+		full := format != MarkdownFormat
+		want, got := fmt.Sprint(exported(items, full)), fmt.Sprint(exported(exportAll(t, s, uid), full))
+		if got != want {
+			t.Errorf("%s: Expected %s got %s", format, want, got)
+		}
+	}
+}
+
+// items with the id or text of one on the list are skipped, and sub-tasks
+// of a skipped item go under the one already there
+func TestImportSkipsDuplicates(t *testing.T) {
+	s := newExportStore(t)
+	items := exportAll(t, s, "tester")
+	if result := importItems(t, s, "tester", items, false); len(result.Added) != 0 || len(result.Skipped) != len(items) {
+		t.Errorf("Expected all %d skipped got %v", len(items), result)
+	}
+
+	trip := NewToDoItem(" PLAN  trip ")
+	pack := NewToDoItem("pack")
+	pack.Parent = trip.ItemId
+	result := importItems(t, s, "tester", []ExportItem{
+		{"", trip},
+		{"", pack},
+		{"shop", NewToDoItem("Eggs")},
+		{"shop", NewToDoItem("bacon")},
+		{"", NewToDoItem("bread")},
+		{"", NewToDoItem("Bread")},
+	}, false)
+	texts := func(items []ExportItem) string {
+		out := make([]string, 0, len(items))
+		for _, v := range items {
+			out = append(out, v.Item)
+		}
+		return fmt.Sprint(out)
+	}
+	if got := texts(result.Added); got != "[pack bacon bread]" {
+		t.Errorf("Expected [pack bacon bread] added got %s", got)
+	}
+	if got := texts(result.Skipped); got != "[ PLAN  trip  Eggs Bread]" {
+		t.Errorf("Expected [ PLAN  trip  Eggs Bread] skipped got %s", got)
+	}
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: "tester", JobType: FetchData})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	if got := parents(ret.List)["pack"]; got != "plan trip" {
+		t.Errorf("Expected pack under plan trip got %q", got)
+	}
+}
+
+// a dry run says what an import would do without doing it
+func TestImportDryRun(t *testing.T) {
+	s := newExportStore(t)
+	want := dumpLists(t, s.activeStore())
+	result := importItems(t, s, "tester", []ExportItem{
+		{"", NewToDoItem("milk")},
+		{"", NewToDoItem("bread")},
+		{"garden", NewToDoItem("mow")},
+	}, true)
+	if len(result.Added) != 2 || len(result.Skipped) != 1 {
+		t.Errorf("Expected 2 added and 1 skipped got %v", result)
+	}
+	if got := dumpLists(t, s.activeStore()); got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+	// nothing went into the history either
+	if ret, err := s.Do(context.Background(), DataStoreJob{Uid: "tester", JobType: UndoData}); err != nil || ret.Op != "add" {
+		t.Errorf("Expected the add of eggs to be undone got %q %v", ret.Op, err)
+	}
+}
@@ -0,0 +1,222 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileStore keeps lists in memory, journals every change and snapshots
+// them to a flat todo file
+type FileStore struct {
+	*MemoryStore
+	filename string
+	// the todo file is in the todo.txt format, see NewToDoTxtStore
+	todoTxt        bool
+	journal        *os.File
+	journalEntries int
+}
+
+func NewFileStore(filename string) *FileStore {
+	return &FileStore{MemoryStore: NewMemoryStore(), filename: filename}
+}
+
+// NewToDoTxtStore is a FileStore that keeps its todo file in the todo.txt
+// format, so other todo.txt tools can read it, see readToDoTxtFile
+func NewToDoTxtStore(filename string) *FileStore {
+	f := NewFileStore(filename)
+	f.todoTxt = true
+	return f
+}
+
+// readFile parses a todo file in the format the store keeps it in
+func (f *FileStore) readFile(r io.Reader) (map[string]baseToDoList, map[string]int64, bool, error) {
+	if f.todoTxt {
+		return readToDoTxtFile(r)
+	}
+	return readToDoFile(r)
+}
+
+// writeFile writes every list in the format the store keeps them in
+func (f *FileStore) writeFile(w io.Writer) error {
+	if f.todoTxt {
+		return writeToDoTxtFile(w, f.lists)
+	}
+	return writeToDoFile(w, f.lists, f.versions)
+}
+
+// Load reads the todo file, creating it if it doesn't exist, and replays
+// its journal. a legacy file, or for a todo.txt store one in the JSON lines
+// format, is rewritten in the current format and the original kept
+// alongside it with a .legacy suffix.
+func (f *FileStore) Load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	lists, versions, legacy, err := f.readFile(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s %w", f.filename, err)
+	}
+	f.setLists(lists, versions)
+
+	if legacy {
+		if err := os.Rename(f.filename, f.filename+".legacy"); err != nil {
+			return err
+		}
+		if err := f.snapshot(); err != nil {
+			return err
+		}
+	}
+	return f.openJournal()
+}
+
+func (f *FileStore) Add(uid string, item ToDoItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item.Id = 0
+	if err := f.appendJournal(newJournalEntry(journalPut, uid, &item)); err != nil {
+		return err
+	}
+	f.add(uid, item)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) Update(uid string, item ToDoItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if itemIndex(f.lists[uid], item.ItemId) == -1 {
+		return NotFoundErr
+	}
+	item.Id = 0
+	if err := f.appendJournal(newJournalEntry(journalPut, uid, &item)); err != nil {
+		return err
+	}
+	f.update(uid, item)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) Put(uid string, idx int, item ToDoItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item.Id = 0
+	entry := newJournalEntry(journalPut, uid, &item)
+	entry.Key = f.placeItem(uid, idx, item.ItemId)
+	if err := f.appendJournal(entry); err != nil {
+		return err
+	}
+	f.put(uid, entry.Key, item)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) Delete(uid string, itemId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	idx := itemIndex(f.lists[uid], itemId)
+	if idx == -1 {
+		return NotFoundErr
+	}
+	item := f.lists[uid][idx]
+	if err := f.appendJournal(newJournalEntry(journalDelete, uid, &item)); err != nil {
+		return err
+	}
+	f.remove(uid, itemId)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) CreateList(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, found := f.lists[key]; found {
+		return f.createList(key)
+	}
+	if err := f.appendJournal(newJournalEntry(journalCreate, key, nil)); err != nil {
+		return err
+	}
+	f.createList(key)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) RenameList(key string, newKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkRename(key, newKey); err != nil {
+		return err
+	}
+	entry := newJournalEntry(journalRename, key, nil)
+	_, entry.To = splitListKey(newKey)
+	if err := f.appendJournal(entry); err != nil {
+		return err
+	}
+	f.renameList(key, newKey)
+	f.compactAfterChange()
+	return nil
+}
+
+func (f *FileStore) DeleteList(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, found := f.lists[key]; !found {
+		return f.deleteList(key)
+	}
+	if err := f.appendJournal(newJournalEntry(journalClear, key, nil)); err != nil {
+		return err
+	}
+	f.deleteList(key)
+	f.compactAfterChange()
+	return nil
+}
+
+// Persist snapshots every list and empties the journal
+func (f *FileStore) Persist() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.persist()
+}
+
+func (f *FileStore) persist() error {
+	if err := f.snapshot(); err != nil {
+		return err
+	}
+	return f.truncateJournal()
+}
+
+// snapshot writes every list to the todo file in the current format
+func (f *FileStore) snapshot() error {
+	var buf bytes.Buffer
+	if err := f.writeFile(&buf); err != nil {
+		return err
+	}
+	return writeSnapshot(f.filename, buf.Bytes())
+}
+
+// Close closes the journal. changes already made are safe in it.
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.journal == nil {
+		return nil
+	}
+	err := f.journal.Close()
+	f.journal = nil
+	return err
+}
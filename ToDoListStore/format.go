@@ -0,0 +1,145 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// the todo file is JSON lines. the first line is a header naming the format
+// and its version, every line after it is one item along with its owner
+// and, for a named list, the list name. each named list also has a line
+// with no item so empty lists are kept. version 3 added named lists and
+// version 4 list versions, which are on the line with no item, written for
+// a default list too once it has one. files written before the header
+// existed hold "uid,item" lines and are migrated the first time they are
+// loaded.
+const fileFormatName = "todo"
+const fileFormatVersion = 4
+
+var UnsupportedFormatErr = fmt.Errorf("unsupported file format")
+
+type fileHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type fileRecord struct {
+	Uid  string `json:"uid"`
+	List string `json:"list,omitempty"`
+	ToDoItem
+}
+
+// listRecord is the line that creates a named list and holds the version
+// of a list. read back as a fileRecord its version is the items.
+type listRecord struct {
+	Uid     string `json:"uid"`
+	List    string `json:"list,omitempty"`
+	Version int64  `json:"version,omitempty"`
+}
+
+// maximum length of a single line in the todo file
+const maxLineLength = 1024 * 1024
+
+// readToDoFile parses either file format, returning the lists and their
+// versions, and reports whether it was legacy
+func readToDoFile(r io.Reader) (map[string]baseToDoList, map[string]int64, bool, error) {
+	lists := make(map[string]baseToDoList)
+	versions := make(map[string]int64)
+	legacy := false
+	header := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		s := scanner.Text()
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		if !header && !legacy {
+			var h fileHeader
+			if json.Unmarshal([]byte(s), &h) == nil && h.Format == fileFormatName {
+				if h.Version > fileFormatVersion {
+					return nil, nil, false, fmt.Errorf("line %d: version %d: %w", lineNo, h.Version, UnsupportedFormatErr)
+				}
+				header = true
+				continue
+			}
+			legacy = true
+		}
+
+		var key string
+		var item ToDoItem
+		if legacy {
+			line := strings.SplitN(s, ",", 2)
+			if len(line) != 2 {
+				return nil, nil, false, fmt.Errorf("line %d: expected uid,item got %q", lineNo, s)
+			}
+			key = line[0]
+			item = NewToDoItem(line[1])
+		} else {
+			var rec fileRecord
+			if err := json.Unmarshal([]byte(s), &rec); err != nil {
+				return nil, nil, false, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if rec.ItemId == "" && rec.List == "" && rec.Version == 0 {
+				return nil, nil, false, fmt.Errorf("line %d: item has no id", lineNo)
+			}
+			key = ListKey(rec.Uid, rec.List)
+			item = rec.ToDoItem
+		}
+
+		userlist, found := lists[key]
+		if !found {
+			userlist = make(baseToDoList)
+			lists[key] = userlist
+		}
+		if item.ItemId == "" && !legacy {
+			// a list record
+			versions[key] = item.Version
+			continue
+		}
+		// items written before they had versions start at 1
+		item.Version = max(item.Version, 1)
+		userlist[getNewKey(userlist)] = item
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, false, fmt.Errorf("line %d: %w", lineNo+1, err)
+	}
+	return lists, versions, legacy, nil
+}
+
+func writeToDoFile(w io.Writer, lists map[string]baseToDoList, versions map[string]int64) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fileHeader{fileFormatName, fileFormatVersion}); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(lists))
+	for key := range lists {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		uid, name := splitListKey(key)
+		if name != "" || versions[key] != 0 {
+			if err := enc.Encode(listRecord{uid, name, versions[key]}); err != nil {
+				return err
+			}
+		}
+		for _, v := range SortedMap(lists[key]) {
+			v.Id = 0
+			if err := enc.Encode(fileRecord{uid, name, v}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+module github.com/simonedz197/ToDoListStore
+
+go 1.24.2
+
+require github.com/google/uuid v1.6.0
@@ -0,0 +1,645 @@
+package ToDoListStore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// every change a job makes to a users lists is kept in their history, as
+// the items and lists before and after it, so it can be undone and redone.
+// a user has one history covering all their lists, holding up to the last
+// 50 changes by default. the history of a file or db backend is kept next
+// to its data once it has been loaded. every change to it is appended to a
+// journal before the job that made it is answered, and the journal is folded
+// into the saved history when the backend is loaded or persisted, when the
+// store is closed and once it has grown to CompactAfter entries.
+
+var NothingToUndoErr = fmt.Errorf("nothing to undo")
+var NothingToRedoErr = fmt.Errorf("nothing to redo")
+
+// how many changes are kept for each user unless told otherwise
+const defaultHistorySize = 50
+
+// the jobs that change a users lists, and what they are called in history
+var undoableJobs = map[JobType]string{
+	AddData:        "add",
+	UpdateData:     "update",
+	DeleteData:     "delete",
+	CompleteData:   "done",
+	ReopenData:     "reopen",
+	TagData:        "tag",
+	UntagData:      "untag",
+	CreateListData: "create list",
+	RenameListData: "rename list",
+	DeleteListData: "delete list",
+	MoveData:       "move",
+	RepeatData:     "repeat",
+	DueData:        "due",
+	RemindData:     "remind",
+	ReorderData:    "reorder",
+	PriorityData:   "priority",
+	ImportData:     "import",
+}
+
+// the changes that can create or delete lists, what they did is looked for
+// in all of a users lists rather than just the one they were made to
+var listOps = map[string]bool{
+	"create list": true,
+	"rename list": true,
+	"delete list": true,
+	"import":      true,
+}
+
+// itemChange is an item before and after a change, Before is nil when it
+// was added and After when it was deleted
+type itemChange struct {
+	Key    string    `json:"key"`
+	Index  int       `json:"index"`
+	Before *ToDoItem `json:"before,omitempty"`
+	After  *ToDoItem `json:"after,omitempty"`
+}
+
+// historyEntry is what one job changed
+type historyEntry struct {
+	Op    string       `json:"op"`
+	Time  time.Time    `json:"time"`
+	Items []itemChange `json:"items,omitempty"`
+	// the keys of the named lists it created and deleted
+	Created []string `json:"created,omitempty"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+type userHistory struct {
+	Undo []historyEntry `json:"undo,omitempty"`
+	Redo []historyEntry `json:"redo,omitempty"`
+}
+
+// what is done to the history, as it is journaled
+const (
+	historyRecord = "record"
+	historyPop    = "pop"
+	historyPush   = "push"
+	historyClear  = "clear"
+)
+
+// historyChange is one change to the history. Redo says whether a pop or
+// push is on the redo stack rather than the undo one.
+type historyChange struct {
+	Op    string        `json:"op"`
+	Uid   string        `json:"uid,omitempty"`
+	Redo  bool          `json:"redo,omitempty"`
+	Entry *historyEntry `json:"entry,omitempty"`
+}
+
+type history struct {
+	mutex sync.Mutex
+	size  int
+	users map[string]*userHistory
+	// every change is appended to the journal, when there is one, before
+	// mutex is let go so it holds them in the order they were made
+	journal   *os.File
+	journaled int
+	// an error appending to the journal, for keepHistory to report
+	failed error
+}
+
+func (h *history) user(uid string) *userHistory {
+	if h.users == nil {
+		h.users = make(map[string]*userHistory)
+	}
+	if h.users[uid] == nil {
+		h.users[uid] = &userHistory{}
+	}
+	return h.users[uid]
+}
+
+// record adds a change to the users history, forgetting what was undone
+func (h *history) record(uid string, entry historyEntry) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.change(historyChange{Op: historyRecord, Uid: uid, Entry: &entry})
+}
+
+// pop takes the latest change off the undo, or redo, stack
+func (h *history) pop(uid string, undo bool) (historyEntry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.change(historyChange{Op: historyPop, Uid: uid, Redo: !undo})
+}
+
+// push puts a change that was undone on the redo stack, or one that was
+// redone back on the undo stack
+func (h *history) push(uid string, entry historyEntry, undone bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.change(historyChange{Op: historyPush, Uid: uid, Redo: undone, Entry: &entry})
+}
+
+func (h *history) clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.change(historyChange{Op: historyClear})
+}
+
+// change makes c and appends it to the journal. it returns the entry taken
+// off a stack by a pop and whether there was one.
+func (h *history) change(c historyChange) (historyEntry, bool) {
+	entry, changed := h.apply(c)
+	if !changed || h.journal == nil {
+		return entry, changed
+	}
+	line, err := json.Marshal(c)
+	if err == nil {
+		_, err = h.journal.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = h.journal.Sync()
+	}
+	if err != nil {
+		h.failed = errors.Join(h.failed, err)
+	} else {
+		h.journaled++
+	}
+	return entry, changed
+}
+
+func (h *history) apply(c historyChange) (historyEntry, bool) {
+	switch c.Op {
+	case historyRecord:
+		size := h.size
+		if size <= 0 {
+			size = defaultHistorySize
+		}
+		user := h.user(c.Uid)
+		user.Undo = append(user.Undo, *c.Entry)
+		if len(user.Undo) > size {
+			user.Undo = user.Undo[len(user.Undo)-size:]
+		}
+		user.Redo = nil
+	case historyPop:
+		stack := h.stack(c.Uid, c.Redo)
+		if len(*stack) == 0 {
+			return historyEntry{}, false
+		}
+		entry := (*stack)[len(*stack)-1]
+		*stack = (*stack)[:len(*stack)-1]
+		return entry, true
+	case historyPush:
+		stack := h.stack(c.Uid, c.Redo)
+		*stack = append(*stack, *c.Entry)
+	case historyClear:
+		h.users = nil
+	}
+	return historyEntry{}, true
+}
+
+func (h *history) stack(uid string, redo bool) *[]historyEntry {
+	if redo {
+		return &h.user(uid).Redo
+	}
+	return &h.user(uid).Undo
+}
+
+// replayJournal makes the changes in the journal name, returning how many
+// there were and a partial last one
+func (h *history) replayJournal(name string) (int, error, error) {
+	return readJournal(name, func(line []byte) error {
+		var c historyChange
+		if err := json.Unmarshal(line, &c); err != nil {
+			return err
+		}
+		switch c.Op {
+		case historyRecord, historyPush:
+			if c.Entry == nil {
+				return fmt.Errorf("%s without an entry", c.Op)
+			}
+		case historyPop, historyClear:
+		default:
+			return fmt.Errorf("unknown history operation %q", c.Op)
+		}
+		h.apply(c)
+		return nil
+	})
+}
+
+// truncateJournal empties the journal once the saved history holds its
+// changes
+func (h *history) truncateJournal() error {
+	if h.journal == nil {
+		return nil
+	}
+	if err := h.journal.Truncate(0); err != nil {
+		return err
+	}
+	h.journaled = 0
+	return h.journal.Sync()
+}
+
+// WithHistorySize sets how many changes are kept for each user to undo,
+// the default is 50
+func WithHistorySize(size int) Option {
+	return func(s *ToDoStore) error {
+		if size < 1 {
+			return fmt.Errorf("history size %d, need at least one", size)
+		}
+		s.history.size = size
+		return nil
+	}
+}
+
+// snapshot returns a copy of the lists in keys, or of every list uid owns
+// when keys is nil, keyed by list key. a named list in keys that doesn't
+// exist is left out.
+func (s *ToDoStore) snapshot(uid string, keys []string) (map[string]map[int]ToDoItem, error) {
+	store := s.activeStore()
+	if keys == nil {
+		names, err := store.Lists(uid)
+		if err != nil {
+			return nil, err
+		}
+		keys = append([]string{uid}, namedKeys(uid, names)...)
+	}
+	lists := make(map[string]map[int]ToDoItem, len(keys))
+	for _, key := range keys {
+		userlist, err := store.Fetch(key)
+		if errors.Is(err, NotFoundErr) && isNamedList(key) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		lists[key] = userlist
+	}
+	return lists, nil
+}
+
+func namedKeys(uid string, names []string) []string {
+	keys := make([]string, 0, len(names))
+	for _, v := range names {
+		keys = append(keys, ListKey(uid, v))
+	}
+	return keys
+}
+
+// diff returns what changed between two snapshots of a users lists
+func diff(op string, before map[string]map[int]ToDoItem, after map[string]map[int]ToDoItem, now time.Time) historyEntry {
+	entry := historyEntry{Op: op, Time: now}
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+		if _, found := after[key]; !found {
+			entry.Deleted = append(entry.Deleted, key)
+		}
+	}
+	for key := range after {
+		if _, found := before[key]; !found {
+			keys = append(keys, key)
+			entry.Created = append(entry.Created, key)
+		}
+	}
+	sort.Strings(keys)
+	sort.Strings(entry.Created)
+	sort.Strings(entry.Deleted)
+
+	for _, key := range keys {
+		was := make(map[string]int, len(before[key]))
+		for idx, v := range before[key] {
+			was[v.ItemId] = idx
+		}
+		changes := make([]itemChange, 0)
+		for idx, v := range after[key] {
+			item := v
+			old, found := was[v.ItemId]
+			if !found {
+				changes = append(changes, itemChange{Key: key, Index: idx, After: &item})
+				continue
+			}
+			delete(was, v.ItemId)
+			if prev := before[key][old]; !reflect.DeepEqual(prev, v) {
+				changes = append(changes, itemChange{Key: key, Index: idx, Before: &prev, After: &item})
+			}
+		}
+		for _, idx := range was {
+			item := before[key][idx]
+			changes = append(changes, itemChange{Key: key, Index: idx, Before: &item})
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Index < changes[j].Index
+		})
+		entry.Items = append(entry.Items, changes...)
+	}
+	return entry
+}
+
+func (e historyEntry) empty() bool {
+	return len(e.Items) == 0 && len(e.Created) == 0 && len(e.Deleted) == 0
+}
+
+// keys returns the keys of the lists the change was made to
+func (e historyEntry) keys() []string {
+	keys := make([]string, 0, len(e.Items)+len(e.Created)+len(e.Deleted))
+	keys = append(append(keys, e.Created...), e.Deleted...)
+	for _, v := range e.Items {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+// recordChange runs change to the list key and adds what it did to the
+// history of the user it belongs to and the audit log, and tells the
+// subscribers
+func (s *ToDoStore) recordChange(ctx context.Context, key string, op string, change func() error) error {
+	uid, _ := splitListKey(key)
+	keys := []string{key}
+	if listOps[op] {
+		keys = nil
+	}
+	entry, err := s.watchChange(uid, keys, op, change)
+	if err == nil {
+		s.changed(ctx, uid, entry, "")
+	}
+	return err
+}
+
+// changed adds a change to the lists uid owns to their history and the
+// audit log, and tells the subscribers
+func (s *ToDoStore) changed(ctx context.Context, uid string, entry historyEntry, detail string) {
+	if entry.empty() {
+		return
+	}
+	s.history.record(uid, entry)
+	s.keepHistory(ctx)
+	s.auditChange(ctx, uid, entry, detail)
+	s.publish(uid, entry)
+}
+
+// watchChange runs change and returns what it did to the lists of uid in
+// keys, or to all of them when keys is nil
+func (s *ToDoStore) watchChange(uid string, keys []string, op string, change func() error) (historyEntry, error) {
+	before, err := s.snapshot(uid, keys)
+	if err != nil {
+		return historyEntry{}, change()
+	}
+	if err := change(); err != nil {
+		return historyEntry{}, err
+	}
+	after, err := s.snapshot(uid, keys)
+	if err != nil {
+		return historyEntry{}, nil
+	}
+	return diff(op, before, after, s.now()), nil
+}
+
+// applyEntry puts a users lists back how they were before the change in
+// entry, or how they were after it when redoing
+func (s *ToDoStore) applyEntry(entry historyEntry, undo bool) error {
+	store := s.activeStore()
+	restore, remove := entry.Created, entry.Deleted
+	if undo {
+		restore, remove = entry.Deleted, entry.Created
+	}
+
+	for _, key := range restore {
+		if err := store.CreateList(key); err != nil && !errors.Is(err, AlreadyExistsErr) {
+			return err
+		}
+	}
+	for _, v := range entry.Items {
+		want, have := v.After, v.Before
+		if undo {
+			want, have = v.Before, v.After
+		}
+		var err error
+		if want != nil {
+			err = store.Put(v.Key, v.Index, s.nextVersion(v.Key, *want))
+		} else {
+			err = store.Delete(v.Key, have.ItemId)
+		}
+		if err != nil && !errors.Is(err, NotFoundErr) {
+			return err
+		}
+	}
+	for _, key := range remove {
+		if err := store.DeleteList(key); err != nil && !errors.Is(err, NotFoundErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// undo undoes, or redoes, the latest change to the lists of the user key
+// belongs to and returns what it was
+func (s *ToDoStore) undo(ctx context.Context, key string, undo bool) (string, error) {
+	uid, _ := splitListKey(key)
+	entry, found := s.history.pop(uid, undo)
+	if !found && undo {
+		return "", NothingToUndoErr
+	}
+	if !found {
+		return "", NothingToRedoErr
+	}
+	op := "redo"
+	if undo {
+		op = "undo"
+	}
+	applied, err := s.watchChange(uid, entry.keys(), op, func() error {
+		return s.applyEntry(entry, undo)
+	})
+	if err != nil {
+		// leave it where it was so it can be tried again
+		s.history.push(uid, entry, !undo)
+		s.keepHistory(ctx)
+		return "", err
+	}
+	s.history.push(uid, entry, undo)
+	s.keepHistory(ctx)
+	s.auditChange(ctx, uid, applied, entry.Op)
+	s.publish(uid, applied)
+	return entry.Op, nil
+}
+
+// fileBacked is implemented by backends that keep their data in a file,
+// the history and audit log are kept in files named after it
+type fileBacked interface {
+	dataFile() string
+}
+
+func (f *FileStore) dataFile() string {
+	return f.filename
+}
+
+func (d *DBStore) dataFile() string {
+	return d.filename
+}
+
+func historyFile(backend fileBacked) string {
+	return backend.dataFile() + ".history"
+}
+
+// saveHistory writes the history next to the backends data and empties its
+// journal
+func (s *ToDoStore) saveHistory() error {
+	backend, ok := s.activeStore().(fileBacked)
+	if !ok {
+		return nil
+	}
+	s.history.mutex.Lock()
+	defer s.history.mutex.Unlock()
+	return s.history.save(historyFile(backend))
+}
+
+func (h *history) save(name string) error {
+	data, err := json.Marshal(h.users)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(name, data); err != nil {
+		return err
+	}
+	return h.truncateJournal()
+}
+
+// keepHistory saves the history once enough changes have built up in its
+// journal, or when one couldn't be journaled. the change itself is already
+// safe in the backend so a failure is only logged.
+func (s *ToDoStore) keepHistory(ctx context.Context) {
+	s.history.mutex.Lock()
+	failed := s.history.failed
+	full := s.history.journal != nil && s.history.journaled >= CompactAfter
+	s.history.failed = nil
+	s.history.mutex.Unlock()
+	if failed != nil {
+		s.Logger.ErrorContext(ctx, fmt.Sprintf("error %v journaling history", failed))
+	}
+	if failed == nil && !full {
+		return
+	}
+	if err := s.saveHistory(); err != nil {
+		s.Logger.ErrorContext(ctx, fmt.Sprintf("error %v saving history", err))
+	}
+}
+
+// loadHistory reads the history saved next to the backends data, replays
+// its journal and opens the journal for appending
+func (s *ToDoStore) loadHistory() error {
+	backend, ok := s.activeStore().(fileBacked)
+	if !ok {
+		return nil
+	}
+	name := historyFile(backend)
+	h := &s.history
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.journal != nil {
+		h.journal.Close()
+		h.journal = nil
+	}
+
+	users := make(map[string]*userHistory)
+	data, err := os.ReadFile(name)
+	if err == nil {
+		if err := json.Unmarshal(data, &users); err != nil {
+			return fmt.Errorf("%s %w", name, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// with nothing saved yet what was done before loading is kept
+	if _, statErr := os.Stat(journalName(name)); err == nil || statErr == nil {
+		h.users = users
+	}
+
+	replayed, torn, err := h.replayJournal(journalName(name))
+	if err != nil {
+		return fmt.Errorf("%s %w", journalName(name), err)
+	}
+	if torn != nil {
+		// the write was never acknowledged so it is safe to drop
+		s.Logger.Warn(fmt.Sprintf("ignoring partial history journal entry %v", torn))
+	}
+	file, err := os.OpenFile(journalName(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	h.journal = file
+	if replayed > 0 || torn != nil {
+		return h.save(name)
+	}
+	return nil
+}
+
+// closeHistory saves the history, when it has been loaded, and closes its
+// journal
+func (s *ToDoStore) closeHistory() error {
+	backend, ok := s.store.(fileBacked)
+	if !ok {
+		return nil
+	}
+	h := &s.history
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.journal == nil {
+		return nil
+	}
+	err := h.save(historyFile(backend))
+	err = errors.Join(err, h.journal.Close())
+	h.journal = nil
+	return err
+}
+
+// UndoToDoList undoes the latest change to the lists of the user in Uid,
+// returning what it was in Op and the list as it is now in List
+func (s *ToDoStore) UndoToDoList(dataJob DataStoreJob) {
+	s.undoJob(dataJob, true)
+}
+
+// RedoToDoList redoes the latest change undone by UndoToDoList
+func (s *ToDoStore) RedoToDoList(dataJob DataStoreJob) {
+	s.undoJob(dataJob, false)
+}
+
+func (s *ToDoStore) undoJob(dataJob DataStoreJob, undo bool) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Op, returnChannelData.Err = s.undo(dataJob.Context, dataJob.Uid, undo)
+	if returnChannelData.Err == nil {
+		returnChannelData.List, returnChannelData.Err = s.activeStore().Fetch(dataJob.key())
+		// the list may have been created by the change
+		if errors.Is(returnChannelData.Err, NotFoundErr) {
+			returnChannelData.List, returnChannelData.Err = s.activeStore().Fetch(dataJob.Uid)
+		}
+	}
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicUndo undoes the latest change to the lists of the user uid, or the
+// list key, belongs to and returns what it was, like "delete"
+func (s *ToDoStore) BasicUndo(uid string) (string, error) {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.undo(context.Background(), uid, true)
+}
+
+// BasicRedo redoes the latest change undone by BasicUndo and returns what
+// it was
+func (s *ToDoStore) BasicRedo(uid string) (string, error) {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.undo(context.Background(), uid, false)
+}
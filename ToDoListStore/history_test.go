@@ -0,0 +1,117 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a change made before the store stops, without persisting it, can be
+// undone once the store is started again
+func TestHistorySavedWithChange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	ctx := context.Background()
+	open := func() *ToDoStore {
+		s, err := New(WithStore(NewFileStore(filename)), WithLogFile(os.DevNull))
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: LoadData}); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return s
+	}
+
+	s := open()
+	for _, job := range []DataStoreJob{
+		{Uid: "tester", JobType: AddData, KeyValue: "milk"},
+		{Uid: "tester", JobType: AddData, KeyValue: "bread"},
+		{Uid: "tester", JobType: UndoData},
+	} {
+		if _, err := s.Do(ctx, job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	s.Close()
+
+	s = open()
+	defer s.Close()
+	ret, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: RedoData})
+	if err != nil || ret.Op != "add" || len(ret.List) != 2 {
+		t.Fatalf("Expected bread to be added again got %q %v %v", ret.Op, ret.List, err)
+	}
+	ret, err = s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UndoData})
+	if err != nil || len(ret.List) != 1 {
+		t.Fatalf("Expected bread to be removed got %v %v", ret.List, err)
+	}
+	ret, err = s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UndoData})
+	if err != nil || len(ret.List) != 0 {
+		t.Errorf("Expected milk to be removed got %v %v", ret.List, err)
+	}
+}
+
+// changes are journaled rather than saved with each one, a store that stops
+// without closing gets its history back from the journal
+func TestHistoryJournalReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	ctx := context.Background()
+	open := func() *ToDoStore {
+		s, err := New(WithStore(NewFileStore(filename)), WithLogFile(os.DevNull))
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: LoadData}); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return s
+	}
+	do := func(s *ToDoStore, job DataStoreJob) ReturnChannelData {
+		t.Helper()
+		job.Uid = "tester"
+		ret, err := s.Do(ctx, job)
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return ret
+	}
+
+	s := open()
+	do(s, DataStoreJob{JobType: AddData, KeyValue: "milk"})
+	do(s, DataStoreJob{JobType: CreateListData, List: "shop"})
+	do(s, DataStoreJob{JobType: AddData, List: "shop", KeyValue: "eggs"})
+	do(s, DataStoreJob{JobType: UndoData, List: "shop"})
+	if _, err := os.Stat(historyFile(NewFileStore(filename))); err == nil {
+		t.Errorf("Expected the history to be journaled not saved")
+	}
+
+	// the store stops here, part way through journaling another change
+	journal, err := os.OpenFile(journalName(historyFile(NewFileStore(filename))), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	journal.WriteString(`{"op":"record","uid":"tester","entry":{"op":"add"`)
+	journal.Close()
+
+	s = open()
+	// loading folds the journal into the saved history
+	if info, err := os.Stat(journalName(historyFile(NewFileStore(filename)))); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty journal got %v %v", info, err)
+	}
+	if ret := do(s, DataStoreJob{JobType: RedoData, List: "shop"}); ret.Op != "add" || len(ret.List) != 1 {
+		t.Errorf("Expected eggs to be added again got %q %v", ret.Op, ret.List)
+	}
+	for _, want := range []string{"add", "create list", "add"} {
+		if ret := do(s, DataStoreJob{JobType: UndoData}); ret.Op != want {
+			t.Errorf("Expected %s to be undone got %q", want, ret.Op)
+		}
+	}
+	if ret := do(s, DataStoreJob{JobType: FetchListsData}); len(ret.Lists) != 1 {
+		t.Errorf("Expected only the default list got %v", ret.Lists)
+	}
+	if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UndoData}); !errors.Is(err, NothingToUndoErr) {
+		t.Errorf("Expected NothingToUndoErr got %v", err)
+	}
+}
@@ -0,0 +1,521 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// items are written to iCalendar, RFC 5545, as a VTODO each. the UID is
+// the items id so a calendar app sees the same to-do every time it reads
+// the feed, and SEQUENCE goes up with its version. DTSTAMP is when the
+// calendar was written, the same for every item. priorities A to H are 1
+// to 8 and the rest 9, the lowest. repeat rules are written as an RRULE
+// counted from a DTSTART of the due date, or when the item was created,
+// reminders as a VALARM each, the parent as RELATED-TO and the list as
+// X-TODO-LIST. reading one back takes what it can of the same, RRULEs
+// that aren't one of the repeat rules are dropped.
+
+const (
+	icalDateTime = "20060102T150405"
+	icalDate     = "20060102"
+	// the longest a line can be before it is folded
+	icalLineLength = 75
+	// the property holding the list of an item
+	icalList = "X-TODO-LIST"
+)
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icalWriter writes content lines, folded and ending with CRLF
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icalWriter) line(name string, value string) {
+	if iw.err != nil {
+		return
+	}
+	line := name + ":" + value
+	for len(line) > icalLineLength {
+		// don't split a character
+		cut := icalLineLength
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, iw.err = io.WriteString(iw.w, line[:cut]+"\r\n"); iw.err != nil {
+			return
+		}
+		line = " " + line[cut:]
+	}
+	_, iw.err = io.WriteString(iw.w, line+"\r\n")
+}
+
+func (iw *icalWriter) text(name string, value string) {
+	iw.line(name, icalEscaper.Replace(value))
+}
+
+func (iw *icalWriter) time(name string, t time.Time) {
+	iw.line(name, t.UTC().Format(icalDateTime)+"Z")
+}
+
+// when writes t as a date when it is midnight and a time otherwise
+func (iw *icalWriter) when(name string, t time.Time) {
+	if local := t.Local(); local.Equal(midnight(local)) {
+		iw.line(name+";VALUE=DATE", local.Format(icalDate))
+		return
+	}
+	iw.time(name, t)
+}
+
+// icalPriority returns the iCalendar priority of a priority letter
+func icalPriority(priority string) int {
+	if priority == "" {
+		return 0
+	}
+	return min(int(priority[0]-'A')+1, 9)
+}
+
+// icalDuration writes how long before an item is due a reminder is
+func icalDuration(d time.Duration) string {
+	days, d := d/(24*time.Hour), d%(24*time.Hour)
+	s := "-P"
+	if days > 0 {
+		s += fmt.Sprintf("%dD", days)
+	}
+	if d > 0 || days == 0 {
+		s += "T"
+		if h := d / time.Hour; h > 0 {
+			s += fmt.Sprintf("%dH", h)
+		}
+		if m := d % time.Hour / time.Minute; m > 0 {
+			s += fmt.Sprintf("%dM", m)
+		}
+		if sec := d % time.Minute / time.Second; sec > 0 || d < time.Minute {
+			s += fmt.Sprintf("%dS", sec)
+		}
+	}
+	return s
+}
+
+// icalRule returns the RRULE of a repeat rule
+func icalRule(rule string, due time.Time) string {
+	r, err := ParseRecurrence(rule, due)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case r.Every > 1:
+		return fmt.Sprintf("FREQ=DAILY;INTERVAL=%d", r.Every)
+	case len(r.Weekdays) > 0:
+		days := make([]string, 0, len(r.Weekdays))
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if r.onWeekday(day) {
+				days = append(days, strings.ToUpper(weekdayNames[day][:2]))
+			}
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+	case r.Day > 0:
+		return fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d", r.Day)
+	}
+	return "FREQ=DAILY"
+}
+
+func writeICal(w io.Writer, items []ExportItem) error {
+	iw := &icalWriter{w: w}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//simonedz197//ToDoListStore//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	stamp := time.Now()
+	for _, v := range items {
+		iw.line("BEGIN", "VTODO")
+		iw.text("UID", v.ItemId)
+		updated := v.Updated
+		if updated.IsZero() {
+			updated = stamp
+		}
+		iw.time("DTSTAMP", stamp)
+		if !v.Created.IsZero() {
+			iw.time("CREATED", v.Created)
+		}
+		iw.time("LAST-MODIFIED", updated)
+		iw.line("SEQUENCE", strconv.FormatInt(max(v.Version-1, 0), 10))
+		iw.text("SUMMARY", v.Item)
+		if v.Notes != "" {
+			iw.text("DESCRIPTION", v.Notes)
+		}
+		if v.Done {
+			iw.line("STATUS", "COMPLETED")
+			completed := v.Completed
+			if completed.IsZero() {
+				completed = updated
+			}
+			iw.time("COMPLETED", completed)
+		} else {
+			iw.line("STATUS", "NEEDS-ACTION")
+		}
+		if p := icalPriority(v.Priority); p != 0 {
+			iw.line("PRIORITY", strconv.Itoa(p))
+		}
+		rule := ""
+		if v.Repeat != "" {
+			rule = icalRule(v.Repeat, v.Due)
+		}
+		if rule != "" {
+			start := v.Due
+			if start.IsZero() {
+				start = v.Created
+			}
+			if start.IsZero() {
+				start = updated
+			}
+			iw.when("DTSTART", start)
+		}
+		if !v.Due.IsZero() {
+			iw.when("DUE", v.Due)
+		}
+		if rule != "" {
+			iw.line("RRULE", rule)
+		}
+		if len(v.Tags) > 0 {
+			tags := make([]string, 0, len(v.Tags))
+			for _, tag := range v.Tags {
+				tags = append(tags, icalEscaper.Replace(tag))
+			}
+			iw.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if v.Parent != "" {
+			iw.text("RELATED-TO", v.Parent)
+		}
+		if v.List != "" {
+			iw.text(icalList, v.List)
+		}
+		for _, reminder := range v.Reminders {
+			before, err := ParseReminder(reminder)
+			if err != nil {
+				continue
+			}
+			iw.line("BEGIN", "VALARM")
+			iw.line("ACTION", "DISPLAY")
+			iw.text("DESCRIPTION", v.Item)
+			iw.line("TRIGGER", icalDuration(before))
+			iw.line("END", "VALARM")
+		}
+		iw.line("END", "VTODO")
+	}
+	iw.line("END", "VCALENDAR")
+	return iw.err
+}
+
+// icalProperty is a content line split into its name, parameters and value
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalLine splits a content line, reporting false for one that isn't
+func parseICalLine(line string) (icalProperty, bool) {
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return icalProperty{}, false
+	}
+	prop := icalProperty{params: make(map[string]string), value: line[colon+1:]}
+	parts := splitUnquoted(line[:colon], ';')
+	prop.name = strings.ToUpper(parts[0])
+	for _, v := range parts[1:] {
+		key, value, _ := strings.Cut(v, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, true
+}
+
+// splitUnquoted splits s at sep outside double quotes
+func splitUnquoted(s string, sep rune) []string {
+	parts := make([]string, 0)
+	quoted := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeICal reads a TEXT value
+func unescapeICal(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// splitICalList splits a TEXT list at the commas that aren't escaped
+func splitICalList(value string) []string {
+	parts := make([]string, 0)
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, unescapeICal(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeICal(value[start:]))
+}
+
+// time reads a DATE or DATE-TIME value, in UTC, its TZID or local time
+func (p icalProperty) time() (time.Time, bool) {
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	value := p.value
+	if strings.HasSuffix(value, "Z") {
+		value, loc = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+	layout := icalDateTime
+	if len(value) == len(icalDate) {
+		layout, loc = icalDate, time.Local
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	return t, err == nil
+}
+
+// icalReminder reads a TRIGGER before an item is due as a reminder,
+// reporting false for one that isn't
+func icalReminder(trigger icalProperty) (string, bool) {
+	if trigger.params["VALUE"] == "DATE-TIME" || trigger.params["RELATED"] == "END" || !strings.HasPrefix(trigger.value, "-P") {
+		return "", false
+	}
+	date, clock, _ := strings.Cut(trigger.value[2:], "T")
+	reminder := ""
+	for _, v := range []struct {
+		value string
+		units string
+	}{{date, "WD"}, {clock, "HMS"}} {
+		rest := v.value
+		for rest != "" {
+			i := strings.IndexFunc(rest, func(r rune) bool {
+				return r < '0' || r > '9'
+			})
+			if i < 1 || !strings.ContainsRune(v.units, rune(rest[i])) {
+				return "", false
+			}
+			reminder += rest[:i] + strings.ToLower(rest[i:i+1])
+			rest = rest[i+1:]
+		}
+	}
+	if _, err := ParseReminder(reminder); err != nil {
+		return "", false
+	}
+	return reminder, true
+}
+
+// icalRepeat reads an RRULE as a repeat rule, reporting false for one that
+// isn't
+func icalRepeat(rrule string, due time.Time) (string, bool) {
+	parts := make(map[string]string)
+	for _, v := range strings.Split(rrule, ";") {
+		key, value, _ := strings.Cut(v, "=")
+		parts[strings.ToUpper(key)] = strings.ToUpper(value)
+	}
+	interval := 1
+	if parts["INTERVAL"] != "" {
+		n, err := strconv.Atoi(parts["INTERVAL"])
+		if err != nil || n < 1 {
+			return "", false
+		}
+		interval = n
+	}
+	if parts["COUNT"] != "" || parts["UNTIL"] != "" {
+		return "", false
+	}
+	rule := ""
+	switch parts["FREQ"] {
+	case "DAILY":
+		rule = "daily"
+		if interval > 1 {
+			rule = fmt.Sprintf("every %d days", interval)
+		}
+	case "WEEKLY":
+		if interval > 1 {
+			return "", false
+		}
+		days := make([]string, 0)
+		for _, v := range strings.Split(parts["BYDAY"], ",") {
+			if v == "" {
+				continue
+			}
+			// a weekly rule can't have numbered days, like 2MO
+			day := -1
+			for i, name := range weekdayNames {
+				if strings.EqualFold(name[:2], v) {
+					day = i
+				}
+			}
+			if day == -1 {
+				return "", false
+			}
+			days = append(days, weekdayNames[day])
+		}
+		rule = "weekly " + strings.Join(days, ",")
+	case "MONTHLY":
+		if interval > 1 || parts["BYDAY"] != "" || strings.Contains(parts["BYMONTHDAY"], ",") {
+			return "", false
+		}
+		rule = "monthly " + parts["BYMONTHDAY"]
+	default:
+		return "", false
+	}
+	r, err := ParseRecurrence(strings.TrimSpace(rule), due)
+	if err != nil {
+		return "", false
+	}
+	return r.String(), true
+}
+
+// readICal reads the VTODOs of an iCalendar file, skipping everything else
+func readICal(r io.Reader) ([]ExportItem, error) {
+	// unfold the lines first
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(lines[0], "\ufeff")), "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file %w", InvalidImportErr)
+	}
+
+	items := make([]ExportItem, 0)
+	var item *ExportItem
+	var rrule string
+	// the component the line is in, inside the VTODO
+	component := ""
+	for _, line := range lines {
+		prop, ok := parseICalLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VTODO"):
+			item = &ExportItem{ToDoItem: NewToDoItem("")}
+			item.Updated = time.Time{}
+			rrule, component = "", ""
+			continue
+		case item == nil:
+			continue
+		case prop.name == "BEGIN":
+			component = strings.ToUpper(prop.value)
+			continue
+		case prop.name == "END" && strings.EqualFold(prop.value, "VTODO"):
+			if rrule != "" {
+				item.Repeat, _ = icalRepeat(rrule, item.Due)
+			}
+			if item.Updated.IsZero() {
+				item.Updated = item.Created
+			}
+			items = append(items, *item)
+			item = nil
+			continue
+		case prop.name == "END":
+			component = ""
+			continue
+		case component == "VALARM":
+			if prop.name == "TRIGGER" {
+				if reminder, ok := icalReminder(prop); ok {
+					item.Reminders = append(item.Reminders, reminder)
+				}
+			}
+			continue
+		case component != "":
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			if uid := unescapeICal(prop.value); uid != "" {
+				item.ItemId = uid
+			}
+		case "SUMMARY":
+			item.Item = unescapeICal(prop.value)
+		case "DESCRIPTION":
+			item.Notes = unescapeICal(prop.value)
+		case "STATUS":
+			item.Done = strings.EqualFold(prop.value, "COMPLETED")
+		case "COMPLETED":
+			item.Completed, _ = prop.time()
+			item.Done = item.Done || !item.Completed.IsZero()
+		case "CREATED":
+			if t, ok := prop.time(); ok {
+				item.Created = t
+			}
+		case "LAST-MODIFIED":
+			item.Updated, _ = prop.time()
+		case "DUE":
+			item.Due, _ = prop.time()
+		case "PRIORITY":
+			if p, err := strconv.Atoi(prop.value); err == nil && p > 0 && p <= 9 {
+				item.Priority = string(rune('A' + p - 1))
+			}
+		case "RRULE":
+			rrule = prop.value
+		case "CATEGORIES":
+			for _, v := range splitICalList(prop.value) {
+				// tags can't have spaces in them, categories can
+				if tag, err := NormaliseTag(strings.Join(strings.Fields(v), "-")); err == nil {
+					item.addTag(tag)
+				}
+			}
+		case "RELATED-TO":
+			if reltype := prop.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+				item.Parent = unescapeICal(prop.value)
+			}
+		case icalList:
+			item.List = unescapeICal(prop.value)
+		}
+	}
+	return items, nil
+}
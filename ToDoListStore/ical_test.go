@@ -0,0 +1,64 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// icalProperties returns the values of name in the calendar, one for each
+// time it is there
+func icalProperties(calendar string, name string) []string {
+	values := make([]string, 0)
+	for _, line := range strings.Split(calendar, "\r\n") {
+		if key, value, found := strings.Cut(line, ":"); found && key == name {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func TestWriteICal(t *testing.T) {
+	created := time.Date(2026, 10, 1, 8, 15, 0, 0, time.UTC)
+	items := []ExportItem{
+		{ToDoItem: ToDoItem{ItemId: "1", Item: "bins", Created: created, Updated: created, Due: time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local), Repeat: "weekly mon"}},
+		{ToDoItem: ToDoItem{ItemId: "2", Item: "water plants", Created: created, Updated: created.Add(time.Hour), Repeat: "every 3 days"}},
+		{ToDoItem: ToDoItem{ItemId: "3", Item: "dentist", Created: created, Due: time.Date(2026, 10, 21, 9, 30, 0, 0, time.UTC)}},
+	}
+	var out bytes.Buffer
+	if err := WriteItems(&out, ICalFormat, items); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	calendar := out.String()
+
+	stamps := icalProperties(calendar, "DTSTAMP")
+	if len(stamps) != 3 || stamps[0] != stamps[1] || stamps[1] != stamps[2] {
+		t.Errorf("Expected one DTSTAMP for every item got %v", stamps)
+	}
+
+	// every RRULE is counted from a DTSTART, the due date or when the item
+	// was created
+	if rules := icalProperties(calendar, "RRULE"); len(rules) != 2 {
+		t.Errorf("Expected two RRULEs got %v", rules)
+	}
+	if starts := icalProperties(calendar, "DTSTART;VALUE=DATE"); len(starts) != 1 || starts[0] != "20261019" {
+		t.Errorf("Expected DTSTART;VALUE=DATE:20261019 got %v", starts)
+	}
+	if starts := icalProperties(calendar, "DTSTART"); len(starts) != 1 || starts[0] != "20261001T081500Z" {
+		t.Errorf("Expected DTSTART:20261001T081500Z got %v", starts)
+	}
+
+	read, err := ReadItems(strings.NewReader(calendar), ICalFormat)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	if len(read) != 3 {
+		t.Fatalf("Expected three items got %v", read)
+	}
+	for i, v := range read {
+		if v.Item != items[i].Item || v.Repeat != items[i].Repeat || !v.Due.Equal(items[i].Due) {
+			t.Errorf("Expected %+v got %+v", items[i].ToDoItem, v.ToDoItem)
+		}
+	}
+}
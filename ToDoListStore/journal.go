@@ -0,0 +1,206 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// every change to a list is appended to a journal next to the todo file
+// before it is applied and acknowledged. loading the todo file replays the
+// journal on top of it, and once the journal has grown to CompactAfter
+// entries it is folded into a fresh snapshot and emptied.
+
+const (
+	journalPut    = "put"
+	journalDelete = "delete"
+	journalClear  = "clear"
+	journalCreate = "create"
+	journalRename = "rename"
+	// sets the version of a list, see DBStore.Persist
+	journalVersion = "version"
+)
+
+// journalEntry is one change. List is empty for a default list, To is the
+// new name of a renamed list and Key is where a put item goes, when it was
+// put somewhere in particular.
+type journalEntry struct {
+	Op   string    `json:"op"`
+	Uid  string    `json:"uid"`
+	List string    `json:"list,omitempty"`
+	To   string    `json:"to,omitempty"`
+	Key  int       `json:"key,omitempty"`
+	Item *ToDoItem `json:"item,omitempty"`
+}
+
+func newJournalEntry(op string, key string, item *ToDoItem) journalEntry {
+	uid, name := splitListKey(key)
+	return journalEntry{Op: op, Uid: uid, List: name, Item: item}
+}
+
+// number of journal entries written before the journal is compacted
+var CompactAfter = 1000
+
+func journalName(filename string) string {
+	return filename + ".journal"
+}
+
+// openJournal replays the journal over the loaded lists and opens it for
+// appending
+func (f *FileStore) openJournal() error {
+	if f.journal != nil {
+		f.journal.Close()
+		f.journal = nil
+	}
+
+	name := journalName(f.filename)
+	replayed, err := f.replayJournal(name)
+	if err != nil {
+		return fmt.Errorf("%s %w", name, err)
+	}
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	f.journal = file
+	f.journalEntries = replayed
+	if replayed > 0 {
+		return f.persist()
+	}
+	return nil
+}
+
+func (f *FileStore) replayJournal(name string) (int, error) {
+	replayed, torn, err := readJournal(name, func(line []byte) error {
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		return f.applyJournalEntry(entry)
+	})
+	if torn != nil {
+		// the write was never acknowledged so it is safe to drop
+		f.log().Warn(fmt.Sprintf("ignoring partial journal entry %v", torn))
+	}
+	return replayed, err
+}
+
+// readJournal calls apply with each line of the journal name and returns how
+// many it applied. only the last line can be a partial write, it is returned
+// in torn rather than as an error.
+func readJournal(name string, apply func(line []byte) error) (int, error, error) {
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	replayed := 0
+	var torn error
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if torn != nil {
+			return replayed, nil, torn
+		}
+		if !json.Valid(scanner.Bytes()) {
+			torn = fmt.Errorf("line %d: not a whole entry", lineNo)
+			continue
+		}
+		if err := apply(scanner.Bytes()); err != nil {
+			return replayed, nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		return replayed, nil, fmt.Errorf("line %d: %w", lineNo+1, err)
+	}
+	return replayed, torn, nil
+}
+
+func (f *FileStore) applyJournalEntry(entry journalEntry) error {
+	key := ListKey(entry.Uid, entry.List)
+	switch entry.Op {
+	case journalPut:
+		if entry.Item == nil {
+			return fmt.Errorf("put without an item")
+		}
+		if entry.Key != 0 {
+			// keys are renumbered when the todo file is read, so the
+			// one the item was put under may have gone to another item
+			f.put(key, f.placeItem(key, entry.Key, entry.Item.ItemId), *entry.Item)
+		} else if f.update(key, *entry.Item) != nil {
+			f.add(key, *entry.Item)
+		}
+	case journalDelete:
+		if entry.Item == nil {
+			return fmt.Errorf("delete without an item")
+		}
+		f.remove(key, entry.Item.ItemId)
+	case journalClear:
+		f.clearList(key)
+	case journalCreate:
+		// the list may already be in the snapshot
+		f.createList(key)
+	case journalRename:
+		if entry.To == "" {
+			return fmt.Errorf("rename without a new name")
+		}
+		f.renameList(key, ListKey(entry.Uid, entry.To))
+	default:
+		return fmt.Errorf("unknown journal operation %q", entry.Op)
+	}
+	return nil
+}
+
+func (f *FileStore) appendJournal(entry journalEntry) error {
+	if f.journal == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := f.journal.Sync(); err != nil {
+		return err
+	}
+	f.journalEntries++
+	return nil
+}
+
+// compactAfterChange snapshots the lists once enough changes have built up
+// in the journal. the change is already safe in the journal so a failure is
+// only logged.
+func (f *FileStore) compactAfterChange() {
+	if f.journal == nil || f.journalEntries < CompactAfter {
+		return
+	}
+	if err := f.persist(); err != nil {
+		f.log().ErrorContext(context.Background(), fmt.Sprintf("error %v compacting journal", err))
+	}
+}
+
+// truncateJournal empties the journal once a snapshot holds its changes
+func (f *FileStore) truncateJournal() error {
+	if f.journal == nil {
+		return nil
+	}
+	if err := f.journal.Truncate(0); err != nil {
+		return err
+	}
+	f.journalEntries = 0
+	return f.journal.Sync()
+}
@@ -0,0 +1,135 @@
+package ToDoListStore
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dumpLists returns every list in store, each item by its id, as JSON so
+// lists read back from a file compare equal to the ones written
+func dumpLists(t *testing.T, store Store) string {
+	t.Helper()
+	keys, err := store.Keys()
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	lists := make(map[string]map[string]ToDoItem, len(keys))
+	for _, key := range keys {
+		userlist, err := store.Fetch(key)
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		lists[key] = make(map[string]ToDoItem, len(userlist))
+		for _, v := range userlist {
+			v.Id = 0
+			lists[key][v.ItemId] = v
+		}
+	}
+	out, err := json.Marshal(lists)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	return string(out)
+}
+
+func loadFileStore(t *testing.T, filename string) *FileStore {
+	t.Helper()
+	f := NewFileStore(filename)
+	// a torn write is warned about, not to a log file in the package
+	f.setLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := f.Load(); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// changes that were only journaled are there after the store is loaded
+// again, just as they were before it stopped
+func TestJournalReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	f := loadFileStore(t, filename)
+
+	milk, bread, eggs := NewToDoItem("milk"), NewToDoItem("bread"), NewToDoItem("eggs")
+	shop, groceries := ListKey("tester", "shop"), ListKey("tester", "groceries")
+	for _, change := range []func() error{
+		func() error { return f.Add("tester", milk) },
+		func() error { return f.Add("tester", bread) },
+		func() error { return f.CreateList(shop) },
+		func() error { return f.Add(shop, eggs) },
+		func() error { return f.RenameList(shop, groceries) },
+		func() error { bread.Item = "rye bread"; return f.Update("tester", bread) },
+		func() error { return f.Delete("tester", milk.ItemId) },
+		func() error { return f.Put("tester", 1, milk) },
+		func() error { return f.CreateList(ListKey("tester", "gone")) },
+		func() error { return f.DeleteList(ListKey("tester", "gone")) },
+	} {
+		if err := change(); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	want := dumpLists(t, f)
+	f.Close()
+
+	// nothing has been snapshotted, the changes are only in the journal
+	if data, _ := os.ReadFile(filename); len(data) != 0 {
+		t.Fatalf("Expected an empty todo file got %q", data)
+	}
+
+	replayed := loadFileStore(t, filename)
+	if got := dumpLists(t, replayed); got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+	// loading folds the journal into a snapshot
+	if info, err := os.Stat(journalName(filename)); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty journal got %v %v", info, err)
+	}
+	replayed.Close()
+
+	if got := dumpLists(t, loadFileStore(t, filename)); got != want {
+		t.Errorf("Expected %s from the snapshot got %s", want, got)
+	}
+}
+
+// a change whose write was cut short was never acknowledged, loading drops
+// it and keeps the ones before it
+func TestJournalReplayTornWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	f := loadFileStore(t, filename)
+	if err := f.Add("tester", NewToDoItem("milk")); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	want := dumpLists(t, f)
+	f.Close()
+
+	journal, err := os.OpenFile(journalName(filename), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	journal.WriteString(`{"op":"put","uid":"tester","item":{"id":"01`)
+	journal.Close()
+
+	if got := dumpLists(t, loadFileStore(t, filename)); got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+}
+
+// only the last line can be torn, a bad line before others is an error
+func TestJournalReplayCorrupt(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	f := loadFileStore(t, filename)
+	f.Close()
+
+	entry, _ := json.Marshal(newJournalEntry(journalPut, "tester", &ToDoItem{ItemId: "1", Item: "milk"}))
+	data := append([]byte("not json\n"), append(entry, '\n')...)
+	if err := os.WriteFile(journalName(filename), data, 0644); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	if err := NewFileStore(filename).Load(); err == nil {
+		t.Errorf("Expected an error loading a corrupt journal")
+	}
+}
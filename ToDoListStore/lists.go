@@ -0,0 +1,293 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// each user has a default list and any number of named lists. a list is
+// stored under a key made from the uid and the list name. the key of the
+// default list is the uid itself, so lists from before named lists existed
+// are the default list, and the Basic* functions work on a named list when
+// they are given its ListKey as the uid.
+
+// DefaultList is the name of the list every user has
+const DefaultList = "default"
+
+// separates the uid from the list name in a list key
+const listKeySeparator = "\x00"
+
+var DefaultListErr = fmt.Errorf("the default list can't be renamed or deleted")
+var InvalidListNameErr = fmt.Errorf("invalid list name")
+
+// ListKey returns the key a users list is stored under
+func ListKey(uid string, name string) string {
+	if name == "" || name == DefaultList {
+		return uid
+	}
+	return uid + listKeySeparator + name
+}
+
+// splitListKey returns the uid and list name of a key. the name is empty
+// for the default list.
+func splitListKey(key string) (string, string) {
+	uid, name, _ := strings.Cut(key, listKeySeparator)
+	return uid, name
+}
+
+// isNamedList reports whether key belongs to a named list rather than a
+// default list
+func isNamedList(key string) bool {
+	return strings.Contains(key, listKeySeparator)
+}
+
+// NormaliseListName returns name in the form it is stored in
+func NormaliseListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 || strings.ContainsAny(name, "/?#\x00\r\n") {
+		return "", fmt.Errorf("%q %w", name, InvalidListNameErr)
+	}
+	return name, nil
+}
+
+// key returns the key of the list a job works on
+func (d DataStoreJob) key() string {
+	return ListKey(d.Uid, d.List)
+}
+
+// namedListKey checks name and returns its key, refusing the default list
+func namedListKey(uid string, name string) (string, error) {
+	name, err := NormaliseListName(name)
+	if err != nil {
+		return "", err
+	}
+	if name == DefaultList {
+		return "", DefaultListErr
+	}
+	return ListKey(uid, name), nil
+}
+
+func (s *ToDoStore) createList(uid string, name string) error {
+	key, err := namedListKey(uid, name)
+	if err == DefaultListErr {
+		// every user has one already
+		return fmt.Errorf("list %q %w", DefaultList, AlreadyExistsErr)
+	}
+	if err != nil {
+		return err
+	}
+	return s.activeStore().CreateList(key)
+}
+
+func (s *ToDoStore) renameList(uid string, name string, newName string) error {
+	key, err := namedListKey(uid, name)
+	if err != nil {
+		return err
+	}
+	newKey, err := namedListKey(uid, newName)
+	if err != nil {
+		return err
+	}
+	return s.activeStore().RenameList(key, newKey)
+}
+
+func (s *ToDoStore) deleteList(uid string, name string) error {
+	key, err := namedListKey(uid, name)
+	if err != nil {
+		return err
+	}
+	return s.activeStore().DeleteList(key)
+}
+
+// userLists returns the names of a users lists, the default list first
+func (s *ToDoStore) userLists(uid string) ([]string, error) {
+	names, err := s.activeStore().Lists(uid)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{DefaultList}, names...), nil
+}
+
+// CreateList creates the list named in List
+func (s *ToDoStore) CreateList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Err = s.createList(dataJob.Uid, dataJob.List)
+	if returnChannelData.Err == nil {
+		returnChannelData.Lists, returnChannelData.Err = s.userLists(dataJob.Uid)
+	}
+	s.reply(dataJob, returnChannelData)
+}
+
+// RenameList renames the list named in List to AltValue
+func (s *ToDoStore) RenameList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Err = s.renameList(dataJob.Uid, dataJob.List, dataJob.AltValue)
+	if returnChannelData.Err == nil {
+		returnChannelData.Lists, returnChannelData.Err = s.userLists(dataJob.Uid)
+	}
+	s.reply(dataJob, returnChannelData)
+}
+
+// DeleteList deletes the list named in List along with its items
+func (s *ToDoStore) DeleteList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Err = s.deleteList(dataJob.Uid, dataJob.List)
+	if returnChannelData.Err == nil {
+		returnChannelData.Lists, returnChannelData.Err = s.userLists(dataJob.Uid)
+	}
+	s.reply(dataJob, returnChannelData)
+}
+
+// FetchLists returns the names of a users lists in Lists
+func (s *ToDoStore) FetchLists(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Lists, returnChannelData.Err = s.userLists(dataJob.Uid)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) BasicCreateList(uid string, name string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "create list", func() error {
+		return s.createList(uid, name)
+	})
+}
+
+func (s *ToDoStore) BasicRenameList(uid string, name string, newName string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "rename list", func() error {
+		return s.renameList(uid, name, newName)
+	})
+}
+
+func (s *ToDoStore) BasicDeleteList(uid string, name string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "delete list", func() error {
+		return s.deleteList(uid, name)
+	})
+}
+
+// BasicLists returns the names of a users lists, the default list first
+func (s *ToDoStore) BasicLists(uid string) ([]string, error) {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	return s.userLists(uid)
+}
+
+func (m *MemoryStore) Lists(uid string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.listNames(uid), nil
+}
+
+func (m *MemoryStore) Keys() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.lists))
+	for key := range m.lists {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *MemoryStore) CreateList(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createList(key)
+}
+
+func (m *MemoryStore) RenameList(key string, newKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.renameList(key, newKey)
+}
+
+func (m *MemoryStore) DeleteList(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteList(key)
+}
+
+func (m *MemoryStore) listNames(uid string) []string {
+	names := make([]string, 0)
+	for key := range m.lists {
+		if owner, name := splitListKey(key); owner == uid && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *MemoryStore) createList(key string) error {
+	if _, found := m.lists[key]; found {
+		_, name := splitListKey(key)
+		return fmt.Errorf("list %q %w", name, AlreadyExistsErr)
+	}
+	m.lists[key] = make(baseToDoList)
+	m.touch(key)
+	return nil
+}
+
+// checkRename returns the error renaming key to newKey would fail with
+func (m *MemoryStore) checkRename(key string, newKey string) error {
+	if _, found := m.lists[key]; !found {
+		_, name := splitListKey(key)
+		return fmt.Errorf("list %q %w", name, NotFoundErr)
+	}
+	if _, found := m.lists[newKey]; found {
+		_, name := splitListKey(newKey)
+		return fmt.Errorf("list %q %w", name, AlreadyExistsErr)
+	}
+	return nil
+}
+
+func (m *MemoryStore) renameList(key string, newKey string) error {
+	if err := m.checkRename(key, newKey); err != nil {
+		return err
+	}
+	m.lists[newKey] = m.lists[key]
+	m.versions[newKey] = max(m.versions[newKey], m.versions[key]) + 1
+	m.clearList(key)
+	delete(m.index, newKey)
+	return nil
+}
+
+func (m *MemoryStore) deleteList(key string) error {
+	if _, found := m.lists[key]; !found {
+		_, name := splitListKey(key)
+		return fmt.Errorf("list %q %w", name, NotFoundErr)
+	}
+	m.clearList(key)
+	return nil
+}
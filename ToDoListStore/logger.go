@@ -0,0 +1,66 @@
+package ToDoListStore
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+type ContextHandler struct {
+	slog.Handler
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if traceid := RequestId(ctx); traceid != "" {
+		r.AddAttrs(slog.String("trace_id", traceid))
+	}
+	if userID, ok := ctx.Value("user_id").(string); ok {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// newFileLogger returns a logger writing to filename. the file isn't
+// created until the first message is logged.
+func newFileLogger(filename string) (*slog.Logger, *logFile) {
+	file := &logFile{filename: filename}
+	baseHandler := slog.NewTextHandler(file, &slog.HandlerOptions{AddSource: true})
+	return slog.New(&ContextHandler{Handler: baseHandler}), file
+}
+
+type logFile struct {
+	mutex    sync.Mutex
+	filename string
+	file     *os.File
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		file, err := os.OpenFile(l.filename, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return 0, err
+		}
+		l.file = file
+	}
+	return l.file.Write(p)
+}
+
+func (l *logFile) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// loggerSetter is implemented by backends that log, so they can share the
+// logger of the store they belong to
+type loggerSetter interface {
+	setLogger(logger *slog.Logger)
+}
@@ -0,0 +1,139 @@
+package ToDoListStore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReminderEvent is sent to the notifiers when one of an items reminders
+// comes due
+type ReminderEvent struct {
+	Uid  string   `json:"uid"`
+	List string   `json:"list,omitempty"`
+	Item ToDoItem `json:"item"`
+	// Before is the reminder that came due, how long before the item is
+	// due, "0" when it is due now
+	Before string `json:"before"`
+	// At is when the reminder was due
+	At time.Time `json:"at"`
+}
+
+func (e ReminderEvent) String() string {
+	due := e.Item.Due.Format("Mon 02 Jan 15:04")
+	if e.Before == "0" {
+		return fmt.Sprintf("%q is due, %s", e.Item.Item, due)
+	}
+	return fmt.Sprintf("%q is due in %s, %s", e.Item.Item, e.Before, due)
+}
+
+// Notifier delivers reminders. Notify is called from the scheduler
+// goroutine, one event at a time.
+type Notifier interface {
+	Notify(ctx context.Context, event ReminderEvent) error
+}
+
+// NotifierFunc lets a function be used as a Notifier
+type NotifierFunc func(ctx context.Context, event ReminderEvent) error
+
+func (f NotifierFunc) Notify(ctx context.Context, event ReminderEvent) error {
+	return f(ctx, event)
+}
+
+// FileNotifier appends each reminder to a file as a line of JSON
+type FileNotifier struct {
+	mutex    sync.Mutex
+	filename string
+}
+
+func NewFileNotifier(filename string) *FileNotifier {
+	return &FileNotifier{filename: filename}
+}
+
+func (f *FileNotifier) Notify(ctx context.Context, event ReminderEvent) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WebhookNotifier posts each reminder as JSON to a url
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, event ReminderEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// MemoryNotifier keeps the latest reminders for each user so a frontend can
+// hand them out when asked
+type MemoryNotifier struct {
+	mutex  sync.Mutex
+	size   int
+	events map[string][]ReminderEvent
+}
+
+// NewMemoryNotifier keeps up to size reminders for each user
+func NewMemoryNotifier(size int) *MemoryNotifier {
+	return &MemoryNotifier{size: size, events: make(map[string][]ReminderEvent)}
+}
+
+func (m *MemoryNotifier) Notify(ctx context.Context, event ReminderEvent) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	events := append(m.events[event.Uid], event)
+	if len(events) > m.size {
+		events = events[len(events)-m.size:]
+	}
+	m.events[event.Uid] = events
+	return nil
+}
+
+// Events returns the reminders kept for uid, oldest first
+func (m *MemoryNotifier) Events(uid string) []ReminderEvent {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]ReminderEvent{}, m.events[uid]...)
+}
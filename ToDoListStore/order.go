@@ -0,0 +1,271 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// items are listed in manual order, by their Rank and then by when they
+// were added. moving an item gives it a rank between its new neighbours so
+// only it changes, until there is no room left between them and the whole
+// list is ranked again. items from before ranks existed have none and stay
+// where they were added until the first move ranks them all. positions are
+// written as
+//
+//	3              third in the list
+//	first, last
+//	before 2       before the item 2 refers to, see findItem
+//	after milk
+
+var InvalidPositionErr = fmt.Errorf("invalid position")
+var InvalidPriorityErr = fmt.Errorf("invalid priority")
+var InvalidSortErr = fmt.Errorf("invalid sort order")
+
+// the orders SortItems can list items in
+const (
+	ManualOrder   = "manual"
+	PriorityOrder = "priority"
+	DueOrder      = "due"
+)
+
+// the room left between the ranks of neighbouring items
+const rankGap = int64(1) << 32
+
+// rank returns where the item at idx goes in manual order
+func rank(idx int, item ToDoItem) int64 {
+	if item.Rank != 0 {
+		return item.Rank
+	}
+	return int64(idx) * rankGap
+}
+
+// orderedKeys returns the keys of userlist in manual order
+func orderedKeys(userlist map[int]ToDoItem) []int {
+	keys := make([]int, 0, len(userlist))
+	for idx := range userlist {
+		keys = append(keys, idx)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := rank(keys[i], userlist[keys[i]]), rank(keys[j], userlist[keys[j]])
+		if a != b {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// lastRank returns the rank of an item added to the end of userlist
+func lastRank(userlist map[int]ToDoItem) int64 {
+	last := int64(0)
+	for idx, v := range userlist {
+		last = max(last, rank(idx, v))
+	}
+	return last + rankGap
+}
+
+// findPosition returns where in order, the keys of the other items in
+// manual order, position puts an item
+func findPosition(userlist map[int]ToDoItem, order []int, position string) (int, error) {
+	position = strings.TrimSpace(position)
+	invalid := fmt.Errorf("%q %w", position, InvalidPositionErr)
+	word, target, _ := strings.Cut(position, " ")
+	switch strings.ToLower(word) {
+	case "first":
+		return 0, nil
+	case "last":
+		return len(order), nil
+	case "before", "after":
+		target = strings.TrimSpace(target)
+		idx := findItem(userlist, target)
+		if idx == -1 {
+			return 0, fmt.Errorf("%q %w", target, NotFoundErr)
+		}
+		at := slices.Index(order, idx)
+		if at == -1 {
+			// the item being moved
+			return 0, invalid
+		}
+		if strings.EqualFold(word, "after") {
+			at++
+		}
+		return at, nil
+	}
+	n, err := strconv.Atoi(position)
+	if err != nil || n < 1 {
+		return 0, invalid
+	}
+	return min(n, len(order)+1) - 1, nil
+}
+
+// between returns a rank for the item at order[at] between its neighbours,
+// reporting false when there is no room
+func between(userlist map[int]ToDoItem, order []int, at int) (int64, bool) {
+	rankAt := func(i int) int64 {
+		return rank(order[i], userlist[order[i]])
+	}
+	var r int64
+	switch {
+	case len(order) == 1:
+		return userlist[order[0]].Rank, true
+	case at == 0:
+		r = rankAt(1) - rankGap
+	case at == len(order)-1:
+		r = rankAt(at-1) + rankGap
+	default:
+		lo, hi := rankAt(at-1), rankAt(at+1)
+		if hi-lo < 2 {
+			return 0, false
+		}
+		r = lo + (hi-lo)/2
+	}
+	// no rank means one from the items key
+	return r, r != 0
+}
+
+// reorderItem moves the item itemKey refers to, to position
+func (s *ToDoStore) reorderItem(key string, itemKey string, position string) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	idx := findItem(userlist, itemKey)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+	was := orderedKeys(userlist)
+	order := slices.DeleteFunc(slices.Clone(was), func(v int) bool {
+		return v == idx
+	})
+	at, err := findPosition(userlist, order, position)
+	if err != nil {
+		return nil, err
+	}
+	order = slices.Insert(order, at, idx)
+	if slices.Equal(order, was) {
+		return userlist, nil
+	}
+
+	ranked := true
+	for _, v := range userlist {
+		ranked = ranked && v.Rank != 0
+	}
+	if r, found := between(userlist, order, at); ranked && found {
+		return s.changeItem(key, userlist[idx].ItemId, func(todo *ToDoItem) {
+			todo.Rank = r
+		})
+	}
+
+	// rank the whole list again, in its new order
+	ranks := make(map[string]int64, len(order))
+	changed := make([]int, 0, len(order))
+	for i, v := range order {
+		ranks[userlist[v].ItemId] = int64(i+1) * rankGap
+		if userlist[v].Rank != ranks[userlist[v].ItemId] {
+			changed = append(changed, v)
+		}
+	}
+	err = updateItems(store, key, userlist, changed, s.now(), func(todo *ToDoItem) {
+		todo.Rank = ranks[todo.ItemId]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store.Fetch(key)
+}
+
+// ParsePriority reads a priority, a letter from A, the highest, to Z. an
+// empty one or "none" is no priority.
+func ParsePriority(priority string) (string, error) {
+	p := strings.ToUpper(strings.TrimSpace(priority))
+	if p == "" || p == "NONE" {
+		return "", nil
+	}
+	if len(p) != 1 || p[0] < 'A' || p[0] > 'Z' {
+		return "", fmt.Errorf("%q %w", priority, InvalidPriorityErr)
+	}
+	return p, nil
+}
+
+// priorityItem sets the priority of the item itemKey refers to, clearing
+// it when priority is empty or "none"
+func (s *ToDoStore) priorityItem(key string, itemKey string, priority string) (map[int]ToDoItem, error) {
+	p, err := ParsePriority(priority)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeItem(key, itemKey, func(todo *ToDoItem) {
+		todo.Priority = p
+	})
+}
+
+// SortItems sorts items, which are in manual order like SortedArray
+// returns them, by order. PriorityOrder puts the highest priority first and
+// DueOrder the soonest due, the items without one last, and items that tie
+// keep their manual order. ManualOrder, or none, leaves them as they are.
+func SortItems(items []ToDoItem, order string) error {
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case ManualOrder, "":
+	case PriorityOrder:
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i].Priority, items[j].Priority
+			return a != "" && (b == "" || a < b)
+		})
+	case DueOrder:
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i].Due, items[j].Due
+			return !a.IsZero() && (b.IsZero() || a.Before(b))
+		})
+	default:
+		return fmt.Errorf("%q %w", order, InvalidSortErr)
+	}
+	return nil
+}
+
+// ReorderToDoItem moves the item in KeyValue to the position in AltValue
+func (s *ToDoStore) ReorderToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.reorderItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+// PriorityToDoItem sets the priority of the item in KeyValue to AltValue,
+// empty or "none" clears it
+func (s *ToDoStore) PriorityToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.priorityItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) BasicReorderToDoItem(uid string, item string, position string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "reorder", func() error {
+		_, err := s.reorderItem(uid, item, position)
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicPriorityToDoItem(uid string, item string, priority string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "priority", func() error {
+		_, err := s.priorityItem(uid, item, priority)
+		return err
+	})
+}
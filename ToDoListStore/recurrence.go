@@ -0,0 +1,302 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a recurring item carries a rule in Repeat and the date of its current
+// occurrence in Due. completing it doesn't leave it done, it moves Due on to
+// the next occurrence and reopens its sub-tasks, ready to go again. rules
+// are written as
+//
+//	daily
+//	weekly mon,thu       on the given weekdays, the weekday it is due if none
+//	monthly 15           on the given day, the last day of shorter months
+//	every 3 days         3 days after it was last completed
+
+var InvalidRepeatErr = fmt.Errorf("invalid repeat rule")
+
+// Recurrence is a parsed repeat rule
+type Recurrence struct {
+	// Every is the number of days after completion, zero for a calendar rule
+	Every int
+	// Weekdays are the days of a weekly rule
+	Weekdays []time.Weekday
+	// Day is the day of the month of a monthly rule
+	Day int
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseRecurrence reads a repeat rule. a weekly rule without weekdays or a
+// monthly rule without a day takes them from ref.
+func ParseRecurrence(rule string, ref time.Time) (Recurrence, error) {
+	fields := strings.FieldsFunc(strings.ToLower(rule), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	invalid := fmt.Errorf("%q %w", rule, InvalidRepeatErr)
+	if len(fields) == 0 {
+		return Recurrence{}, invalid
+	}
+
+	switch fields[0] {
+	case "daily":
+		if len(fields) != 1 {
+			return Recurrence{}, invalid
+		}
+		return Recurrence{}, nil
+	case "weekly":
+		r := Recurrence{}
+		for _, v := range fields[1:] {
+			day := weekdayIndex(v)
+			if day == -1 {
+				return Recurrence{}, invalid
+			}
+			if !r.onWeekday(time.Weekday(day)) {
+				r.Weekdays = append(r.Weekdays, time.Weekday(day))
+			}
+		}
+		if len(r.Weekdays) == 0 {
+			r.Weekdays = []time.Weekday{ref.Weekday()}
+		}
+		return r, nil
+	case "monthly":
+		if len(fields) > 2 {
+			return Recurrence{}, invalid
+		}
+		r := Recurrence{Day: ref.Day()}
+		if len(fields) == 2 {
+			day, err := strconv.Atoi(strings.TrimRight(fields[1], "stndrh"))
+			if err != nil || day < 1 || day > 31 {
+				return Recurrence{}, invalid
+			}
+			r.Day = day
+		}
+		return r, nil
+	case "every":
+		// every N days, or every day
+		if len(fields) == 2 && fields[1] == "day" {
+			return Recurrence{}, nil
+		}
+		if len(fields) != 3 || (fields[2] != "days" && fields[2] != "day") {
+			return Recurrence{}, invalid
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return Recurrence{}, invalid
+		}
+		return Recurrence{Every: n}, nil
+	}
+	return Recurrence{}, invalid
+}
+
+func weekdayIndex(name string) int {
+	if len(name) < 3 {
+		return -1
+	}
+	for i, v := range weekdayNames {
+		if strings.HasPrefix(name, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r Recurrence) onWeekday(day time.Weekday) bool {
+	for _, v := range r.Weekdays {
+		if v == day {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the rule in the form it is stored in
+func (r Recurrence) String() string {
+	switch {
+	case r.Every == 1:
+		return "every 1 day"
+	case r.Every > 1:
+		return fmt.Sprintf("every %d days", r.Every)
+	case len(r.Weekdays) > 0:
+		days := make([]string, 0, len(r.Weekdays))
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if r.onWeekday(day) {
+				days = append(days, weekdayNames[day])
+			}
+		}
+		return "weekly " + strings.Join(days, ",")
+	case r.Day > 0:
+		return fmt.Sprintf("monthly %d", r.Day)
+	}
+	return "daily"
+}
+
+// matches reports whether a calendar rule falls on date
+func (r Recurrence) matches(date time.Time) bool {
+	switch {
+	case len(r.Weekdays) > 0:
+		return r.onWeekday(date.Weekday())
+	case r.Day > 0:
+		last := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day()
+		return date.Day() == min(r.Day, last)
+	}
+	return true
+}
+
+// First returns the first occurrence on or after now
+func (r Recurrence) First(now time.Time) time.Time {
+	return r.search(midnight(now), 0)
+}
+
+// Next returns the occurrence after one due at due was completed at
+// completed. calendar rules move to the first date after both, so a late
+// completion doesn't leave the item overdue, and the time of day it was
+// due is kept.
+func (r Recurrence) Next(due time.Time, completed time.Time) time.Time {
+	clock := time.Duration(0)
+	if !due.IsZero() {
+		clock = due.Sub(midnight(due))
+	}
+	if r.Every > 0 {
+		return midnight(completed).AddDate(0, 0, r.Every).Add(clock)
+	}
+	from := completed
+	if due.After(from) {
+		from = due
+	}
+	return r.search(midnight(from), 1).Add(clock)
+}
+
+// search returns the first date the rule falls on, starting skip days after
+// from
+func (r Recurrence) search(from time.Time, skip int) time.Time {
+	if r.Every > 0 {
+		return from
+	}
+	// every rule falls at least once in any two months
+	for i := skip; i < skip+62; i++ {
+		date := from.AddDate(0, 0, i)
+		if r.matches(date) {
+			return date
+		}
+	}
+	return from.AddDate(0, 0, skip)
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// repeatItem sets the repeat rule of the item itemKey refers to, or clears
+// it when rule is empty or "never". an item without a due date, or due on a
+// date the rule doesn't fall on, becomes due on the first occurrence.
+func (s *ToDoStore) repeatItem(key string, itemKey string, rule string) (map[int]ToDoItem, error) {
+	rule = strings.TrimSpace(rule)
+	if rule == "" || strings.EqualFold(rule, "never") {
+		return s.changeItem(key, itemKey, func(todo *ToDoItem) {
+			todo.Repeat = ""
+		})
+	}
+
+	now := s.now()
+	userlist, err := s.activeStore().Fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	idx := findItem(userlist, itemKey)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+	ref := userlist[idx].Due
+	if ref.IsZero() {
+		ref = now
+	}
+	r, err := ParseRecurrence(rule, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeItem(key, userlist[idx].ItemId, func(todo *ToDoItem) {
+		todo.Repeat = r.String()
+		if todo.Due.IsZero() || !r.matches(todo.Due) {
+			todo.Due = r.First(now)
+		}
+	})
+}
+
+// newRecurring sets the repeat rule of a new item, due when the rule first
+// comes round after now. an empty rule, or never, leaves it as it is.
+func newRecurring(todo *ToDoItem, rule string, now time.Time) error {
+	rule = strings.TrimSpace(rule)
+	if rule == "" || strings.EqualFold(rule, "never") {
+		return nil
+	}
+	r, err := ParseRecurrence(rule, now)
+	if err != nil {
+		return err
+	}
+	todo.Repeat, todo.Due = r.String(), r.First(now)
+	return nil
+}
+
+// completeRecurring moves a recurring item on to its next occurrence and
+// reopens its sub-tasks
+func completeRecurring(store Store, key string, userlist map[int]ToDoItem, idx int, now time.Time) error {
+	todo := userlist[idx]
+	r, err := ParseRecurrence(todo.Repeat, todo.Due)
+	if err != nil {
+		return err
+	}
+	if err := updateItems(store, key, userlist, descendants(userlist, todo.ItemId), now, func(child *ToDoItem) {
+		child.Done = false
+		child.Completed = time.Time{}
+	}); err != nil {
+		return err
+	}
+	return updateItems(store, key, userlist, []int{idx}, now, func(todo *ToDoItem) {
+		todo.Done = false
+		todo.Completed = now
+		todo.Due = r.Next(todo.Due, now)
+	})
+}
+
+// RepeatToDoItem sets the repeat rule of the item in KeyValue to AltValue,
+// an empty rule or "never" stops it repeating
+func (s *ToDoStore) RepeatToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.repeatItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicAddRecurringToDoItem adds item, as a sub-task of the item parent
+// refers to when it is set, repeating by rule, as one change
+func (s *ToDoStore) BasicAddRecurringToDoItem(uid string, parent string, item string, rule string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "add", func() error {
+		_, err := s.addItem(uid, item, parent, rule)
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicRepeatToDoItem(uid string, item string, rule string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "repeat", func() error {
+		_, err := s.repeatItem(uid, item, rule)
+		return err
+	})
+}
@@ -0,0 +1,100 @@
+package ToDoListStore
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int, hour int, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseRecurrence(t *testing.T) {
+	// a Monday
+	ref := date(2026, 10, 19, 9, 0)
+	for _, test := range []struct {
+		rule string
+		want string
+	}{
+		{"daily", "daily"},
+		{"every day", "daily"},
+		{"every 1 day", "every 1 day"},
+		{"every 3 days", "every 3 days"},
+		{"weekly", "weekly mon"},
+		{"weekly thursday, mon", "weekly mon,thu"},
+		{"monthly", "monthly 19"},
+		{"Monthly 31st", "monthly 31"},
+	} {
+		r, err := ParseRecurrence(test.rule, ref)
+		if err != nil {
+			t.Errorf("%q: Expected nil got %v", test.rule, err)
+			continue
+		}
+		if r.String() != test.want {
+			t.Errorf("%q: Expected %q got %q", test.rule, test.want, r.String())
+		}
+	}
+
+	for _, rule := range []string{"", "sometimes", "daily 2", "weekly mo", "monthly 32", "monthly 1 2", "every 0 days", "every 3 weeks"} {
+		if _, err := ParseRecurrence(rule, ref); !errors.Is(err, InvalidRepeatErr) {
+			t.Errorf("%q: Expected InvalidRepeatErr got %v", rule, err)
+		}
+	}
+}
+
+func TestRecurrenceFirst(t *testing.T) {
+	// a Monday
+	now := date(2026, 10, 19, 15, 30)
+	for _, test := range []struct {
+		rule string
+		want time.Time
+	}{
+		{"daily", date(2026, 10, 19, 0, 0)},
+		{"every 3 days", date(2026, 10, 19, 0, 0)},
+		{"weekly mon", date(2026, 10, 19, 0, 0)},
+		{"weekly thu,sat", date(2026, 10, 22, 0, 0)},
+		{"monthly 5", date(2026, 11, 5, 0, 0)},
+		{"monthly 31", date(2026, 10, 31, 0, 0)},
+	} {
+		r, err := ParseRecurrence(test.rule, now)
+		if err != nil {
+			t.Fatalf("%q: Expected nil got %v", test.rule, err)
+		}
+		if got := r.First(now); !got.Equal(test.want) {
+			t.Errorf("%q: Expected %v got %v", test.rule, test.want, got)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		rule      string
+		due       time.Time
+		completed time.Time
+		want      time.Time
+	}{
+		{"daily", "daily", date(2026, 10, 19, 9, 0), date(2026, 10, 19, 10, 0), date(2026, 10, 20, 9, 0)},
+		{"daily done early", "daily", date(2026, 10, 19, 9, 0), date(2026, 10, 18, 20, 0), date(2026, 10, 20, 9, 0)},
+		{"daily done late", "daily", date(2026, 10, 19, 9, 0), date(2026, 10, 22, 10, 0), date(2026, 10, 23, 9, 0)},
+		{"every n days from completion", "every 3 days", date(2026, 10, 19, 9, 0), date(2026, 10, 21, 18, 0), date(2026, 10, 24, 9, 0)},
+		{"every day without a due date", "every 1 day", time.Time{}, date(2026, 10, 21, 18, 0), date(2026, 10, 22, 0, 0)},
+		{"weekly next day in the week", "weekly mon,thu", date(2026, 10, 19, 7, 30), date(2026, 10, 19, 8, 0), date(2026, 10, 22, 7, 30)},
+		{"weekly next week", "weekly mon,thu", date(2026, 10, 22, 7, 30), date(2026, 10, 22, 8, 0), date(2026, 10, 26, 7, 30)},
+		{"weekly done late", "weekly mon,thu", date(2026, 10, 19, 7, 30), date(2026, 10, 23, 8, 0), date(2026, 10, 26, 7, 30)},
+		{"monthly", "monthly 15", date(2026, 10, 15, 12, 0), date(2026, 10, 15, 13, 0), date(2026, 11, 15, 12, 0)},
+		{"monthly end of a short month", "monthly 31", date(2026, 1, 31, 12, 0), date(2026, 1, 31, 13, 0), date(2026, 2, 28, 12, 0)},
+		{"monthly back to the 31st", "monthly 31", date(2026, 2, 28, 12, 0), date(2026, 2, 28, 13, 0), date(2026, 3, 31, 12, 0)},
+		{"monthly leap year", "monthly 30", date(2028, 1, 30, 12, 0), date(2028, 1, 30, 13, 0), date(2028, 2, 29, 12, 0)},
+		{"monthly done late", "monthly 15", date(2026, 10, 15, 12, 0), date(2026, 11, 20, 9, 0), date(2026, 12, 15, 12, 0)},
+	} {
+		r, err := ParseRecurrence(test.rule, test.due)
+		if err != nil {
+			t.Fatalf("%s: Expected nil got %v", test.name, err)
+		}
+		if got := r.Next(test.due, test.completed); !got.Equal(test.want) {
+			t.Errorf("%s: Expected %v got %v", test.name, test.want, got)
+		}
+	}
+}
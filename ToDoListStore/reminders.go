@@ -0,0 +1,355 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// an item can have a due date and reminders, each one an offset before it
+// is due like "1d" or "30m". the scheduler, ProcessReminders, looks for
+// reminders that have come due every so often and sends them to the stores
+// notifiers, along with one when the item itself is due. Reminded records
+// when the item was last reminded so each reminder is only sent once, and
+// when several have come due since the last check only the latest is sent.
+
+var InvalidDueErr = fmt.Errorf("invalid due date")
+var InvalidReminderErr = fmt.Errorf("invalid reminder")
+
+// how often the scheduler checks for reminders unless told otherwise
+const defaultReminderInterval = time.Minute
+
+var dueLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// ParseDue reads a due date, "today", "tomorrow", a date like 2026-10-21 or
+// a date and time like 2026-10-21 09:30, in the location of now
+func ParseDue(due string, now time.Time) (time.Time, error) {
+	due = strings.TrimSpace(due)
+	switch strings.ToLower(due) {
+	case "today":
+		return midnight(now), nil
+	case "tomorrow":
+		return midnight(now).AddDate(0, 0, 1), nil
+	}
+	for _, layout := range dueLayouts {
+		if t, err := time.ParseInLocation(layout, due, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q %w", due, InvalidDueErr)
+}
+
+// ParseReminder reads how long before an item is due a reminder is, a
+// duration like 90m or 1h30m that can also have weeks and days, 1w or 2d12h
+func ParseReminder(before string) (time.Duration, error) {
+	before = strings.ToLower(strings.TrimSpace(before))
+	invalid := fmt.Errorf("%q %w", before, InvalidReminderErr)
+	if before == "" {
+		return 0, invalid
+	}
+
+	total := time.Duration(0)
+	rest := before
+	// time.ParseDuration doesn't know about weeks or days
+	for _, unit := range []struct {
+		suffix string
+		length time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		count, after, found := strings.Cut(rest, unit.suffix)
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		total += time.Duration(n) * unit.length
+		rest = after
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, invalid
+		}
+		total += d
+	}
+	if total < 0 {
+		return 0, invalid
+	}
+	return total, nil
+}
+
+// FormatReminder returns a reminder in the form it is stored in
+func FormatReminder(before time.Duration) string {
+	if before <= 0 {
+		return "0"
+	}
+	out := ""
+	for _, unit := range []struct {
+		suffix string
+		length time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := before / unit.length; n > 0 {
+			out += fmt.Sprintf("%d%s", n, unit.suffix)
+			before -= n * unit.length
+		}
+	}
+	if out == "" {
+		return before.String()
+	}
+	return out
+}
+
+// parseReminders reads a comma or space separated list of reminders,
+// returning them earliest first without duplicates
+func parseReminders(reminders string) ([]string, error) {
+	fields := strings.FieldsFunc(reminders, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	offsets := make([]time.Duration, 0, len(fields))
+	for _, v := range fields {
+		d, err := ParseReminder(v)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, d)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] > offsets[j]
+	})
+
+	parsed := make([]string, 0, len(offsets))
+	for i, v := range offsets {
+		if i == 0 || v != offsets[i-1] {
+			parsed = append(parsed, FormatReminder(v))
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, nil
+	}
+	return parsed, nil
+}
+
+// dueReminder returns the latest reminder of item, or its due date, that
+// has come due by now since it was last reminded
+func dueReminder(item ToDoItem, now time.Time) (time.Time, time.Duration, bool) {
+	var at time.Time
+	var before time.Duration
+	found := false
+	if item.Done || item.Due.IsZero() {
+		return at, before, found
+	}
+	for _, v := range append([]string{"0"}, item.Reminders...) {
+		d, err := ParseReminder(v)
+		if err != nil {
+			continue
+		}
+		t := item.Due.Add(-d)
+		if t.After(now) || !t.After(item.Reminded) {
+			continue
+		}
+		if !found || t.After(at) {
+			at, before, found = t, d, true
+		}
+	}
+	return at, before, found
+}
+
+// dueItem sets the due date of the item itemKey refers to, or clears it
+// when due is empty or "none". its reminders start again from the new date.
+func (s *ToDoStore) dueItem(key string, itemKey string, due string) (map[int]ToDoItem, error) {
+	when := time.Time{}
+	if due = strings.TrimSpace(due); due != "" && !strings.EqualFold(due, "none") {
+		var err error
+		if when, err = ParseDue(due, s.now()); err != nil {
+			return nil, err
+		}
+	}
+	return s.changeItem(key, itemKey, func(todo *ToDoItem) {
+		todo.Due = when
+		todo.Reminded = time.Time{}
+	})
+}
+
+// remindItem replaces the reminders of the item itemKey refers to, clearing
+// them when reminders is empty or "none"
+func (s *ToDoStore) remindItem(key string, itemKey string, reminders string) (map[int]ToDoItem, error) {
+	var parsed []string
+	if reminders = strings.TrimSpace(reminders); !strings.EqualFold(reminders, "none") {
+		var err error
+		if parsed, err = parseReminders(reminders); err != nil {
+			return nil, err
+		}
+	}
+	return s.changeItem(key, itemKey, func(todo *ToDoItem) {
+		todo.Reminders = parsed
+	})
+}
+
+// DueToDoItem sets the due date of the item in KeyValue to AltValue, an
+// empty date or "none" clears it
+func (s *ToDoStore) DueToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.dueItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+// RemindToDoItem sets the reminders of the item in KeyValue to the comma
+// separated list in AltValue, empty or "none" clears them
+func (s *ToDoStore) RemindToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.remindItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) BasicDueToDoItem(uid string, item string, due string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "due", func() error {
+		_, err := s.dueItem(uid, item, due)
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicRemindToDoItem(uid string, item string, reminders string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "remind", func() error {
+		_, err := s.remindItem(uid, item, reminders)
+		return err
+	})
+}
+
+// WithNotifier adds a notifier reminders are sent to
+func WithNotifier(notifier Notifier) Option {
+	return func(s *ToDoStore) error {
+		s.notifiers = append(s.notifiers, notifier)
+		return nil
+	}
+}
+
+// WithReminderInterval sets how often the scheduler checks for reminders,
+// the default is once a minute
+func WithReminderInterval(interval time.Duration) Option {
+	return func(s *ToDoStore) error {
+		if interval <= 0 {
+			return fmt.Errorf("reminder interval %v isn't positive", interval)
+		}
+		s.reminderInterval = interval
+		return nil
+	}
+}
+
+// AddNotifier adds a notifier reminders are sent to
+func (s *ToDoStore) AddNotifier(notifier Notifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifiers = append(s.notifiers, notifier)
+}
+
+// ProcessReminders is the scheduler. it checks for reminders that have
+// come due, by the stores clock, until the store is closed.
+func (s *ToDoStore) ProcessReminders() {
+	interval := s.reminderInterval
+	if interval <= 0 {
+		interval = defaultReminderInterval
+	}
+	for {
+		select {
+		case <-s.activeClock().After(interval):
+			s.CheckReminders(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// CheckReminders sends the reminders that have come due since the last
+// check to the notifiers and returns them
+func (s *ToDoStore) CheckReminders(ctx context.Context) []ReminderEvent {
+	s.mutex.Lock()
+	events, err := s.dueReminders(ctx)
+	notifiers := append([]Notifier{}, s.notifiers...)
+	s.mutex.Unlock()
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "Error checking reminders", "details", err)
+	}
+
+	for _, event := range events {
+		for _, notifier := range notifiers {
+			if err := notifier.Notify(ctx, event); err != nil {
+				s.Logger.ErrorContext(ctx, "Error sending reminder", "details", err)
+			}
+		}
+	}
+	return events
+}
+
+// dueReminders marks the reminders that have come due as sent and returns
+// them, oldest first. marking an item moves its version on like any other
+// change, so it is audited and sent to subscribers, but it isn't one the
+// user made so it isn't added to their undo history.
+func (s *ToDoStore) dueReminders(ctx context.Context) ([]ReminderEvent, error) {
+	events := make([]ReminderEvent, 0)
+	// nothing has been loaded yet
+	if s.store == nil {
+		return events, nil
+	}
+	keys, err := s.store.Keys()
+	if err != nil {
+		return events, err
+	}
+	now := s.now()
+	for _, key := range keys {
+		userlist, err := s.store.Fetch(key)
+		if err != nil {
+			return events, err
+		}
+		uid, name := splitListKey(key)
+		due := make([]ReminderEvent, 0)
+		for _, v := range SortedArray(userlist) {
+			if at, before, found := dueReminder(v, now); found {
+				due = append(due, ReminderEvent{Uid: uid, List: name, Item: v, Before: FormatReminder(before), At: at})
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		entry, err := s.watchChange(uid, []string{key}, "reminded", func() error {
+			for i := range due {
+				due[i].Item.Reminded = now
+				due[i].Item.Version++
+				if err := s.store.Update(key, due[i].Item); err != nil {
+					return err
+				}
+				events = append(events, due[i])
+			}
+			return nil
+		})
+		if !entry.empty() {
+			s.auditChange(ctx, uid, entry, "")
+			s.publish(uid, entry)
+		}
+		if err != nil {
+			return events, err
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+	return events, nil
+}
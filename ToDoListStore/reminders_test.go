@@ -0,0 +1,232 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// the reminder tests run on a fake clock, starting the Monday before the
+// dentist is due on Wednesday at 09:00
+var reminderStart = time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+func newReminderStore(t *testing.T, clock *FakeClock, opts ...Option) *ToDoStore {
+	t.Helper()
+	opts = append([]Option{WithStore(NewMemoryStore()), WithClock(clock), WithLogFile(os.DevNull), WithAuditLog(NewMemoryAuditLog())}, opts...)
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// addDue adds item, due at due with reminders, for uid
+func addDue(t *testing.T, s *ToDoStore, uid string, item string, due string, reminders string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, job := range []DataStoreJob{
+		{Uid: uid, JobType: AddData, KeyValue: item},
+		{Uid: uid, JobType: DueData, KeyValue: item, AltValue: due},
+		{Uid: uid, JobType: RemindData, KeyValue: item, AltValue: reminders},
+	} {
+		if _, err := s.Do(ctx, job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+}
+
+func fetchItem(t *testing.T, s *ToDoStore, uid string, item string) ToDoItem {
+	t.Helper()
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: uid, JobType: FetchData})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	for _, v := range ret.List {
+		if v.Item == item {
+			return v
+		}
+	}
+	t.Fatalf("Expected %q in %v", item, ret.List)
+	return ToDoItem{}
+}
+
+// befores returns the Before of each event
+func befores(events []ReminderEvent) []string {
+	out := make([]string, 0, len(events))
+	for _, v := range events {
+		out = append(out, v.Before)
+	}
+	return out
+}
+
+func TestCheckReminders(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	s := newReminderStore(t, clock)
+	addDue(t, s, "tester", "dentist", "2026-10-21 09:00", "1d,30m")
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		{"nothing due yet", reminderStart, []string{}},
+		{"a day before", time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC), []string{"1d"}},
+		{"already reminded", time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC), []string{}},
+		{"half an hour before", time.Date(2026, 10, 21, 8, 30, 0, 0, time.UTC), []string{"30m"}},
+		{"due", time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC), []string{"0"}},
+		{"after it was due", time.Date(2026, 10, 22, 9, 0, 0, 0, time.UTC), []string{}},
+	} {
+		clock.Set(test.at)
+		events := s.CheckReminders(ctx)
+		if fmt.Sprint(befores(events)) != fmt.Sprint(test.want) {
+			t.Errorf("%s: Expected %v got %v", test.name, test.want, befores(events))
+			continue
+		}
+		for _, v := range events {
+			if v.Uid != "tester" || v.Item.Item != "dentist" || v.At.After(test.at) {
+				t.Errorf("%s: Unexpected event %v", test.name, v)
+			}
+		}
+	}
+}
+
+func TestCheckRemindersLatestOnly(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	s := newReminderStore(t, clock)
+	addDue(t, s, "tester", "dentist", "2026-10-21 09:00", "1d,30m")
+
+	// the store was down for both reminders, only the due date is sent
+	clock.Set(time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC))
+	events := s.CheckReminders(context.Background())
+	if len(events) != 1 || events[0].Before != "0" {
+		t.Errorf("Expected one event for the due date got %v", befores(events))
+	}
+}
+
+func TestCheckRemindersSkipsDone(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	s := newReminderStore(t, clock)
+	addDue(t, s, "tester", "dentist", "2026-10-21 09:00", "1d")
+	if _, err := s.Do(context.Background(), DataStoreJob{Uid: "tester", JobType: CompleteData, KeyValue: "dentist"}); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+
+	clock.Set(time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC))
+	if events := s.CheckReminders(context.Background()); len(events) != 0 {
+		t.Errorf("Expected no events got %v", befores(events))
+	}
+}
+
+func TestCheckRemindersMarksItem(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	s := newReminderStore(t, clock)
+	addDue(t, s, "tester", "dentist", "2026-10-21 09:00", "1d")
+	ctx := context.Background()
+	before := fetchItem(t, s, "tester", "dentist")
+	sub := s.Subscribe("tester", ChangeFilter{})
+	defer sub.Close()
+
+	at := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	clock.Set(at)
+	if events := s.CheckReminders(ctx); len(events) != 1 {
+		t.Fatalf("Expected one event got %v", befores(events))
+	}
+
+	after := fetchItem(t, s, "tester", "dentist")
+	if !after.Reminded.Equal(at) {
+		t.Errorf("Expected reminded at %v got %v", at, after.Reminded)
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("Expected version %d got %d", before.Version+1, after.Version)
+	}
+
+	select {
+	case event := <-sub.C:
+		if event.Op != "reminded" || event.Kind != ItemUpdated || event.Item.Version != after.Version {
+			t.Errorf("Unexpected change %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected a change to be published")
+	}
+
+	audited, err := s.auditLog().Query(AuditFilter{Uid: "tester", Op: "reminded"})
+	if err != nil || len(audited) != 1 {
+		t.Errorf("Expected one audit event got %v %v", audited, err)
+	}
+
+	// marking the item isn't a change the user can undo
+	ret, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UndoData})
+	if err != nil || ret.Op != "remind" {
+		t.Errorf("Expected remind to be undone got %q %v", ret.Op, err)
+	}
+}
+
+func TestCheckRemindersNotifiers(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	memory := NewMemoryNotifier(10)
+	var mutex sync.Mutex
+	sent := make([]ReminderEvent, 0)
+	failing := NotifierFunc(func(ctx context.Context, event ReminderEvent) error {
+		return fmt.Errorf("not delivered")
+	})
+	recording := NotifierFunc(func(ctx context.Context, event ReminderEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent = append(sent, event)
+		return nil
+	})
+	s := newReminderStore(t, clock, WithNotifier(failing), WithNotifier(memory), WithNotifier(recording))
+	addDue(t, s, "tester", "dentist", "2026-10-21 09:00", "")
+	addDue(t, s, "other", "haircut", "2026-10-21 09:00", "")
+
+	clock.Set(time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC))
+	if events := s.CheckReminders(context.Background()); len(events) != 2 {
+		t.Fatalf("Expected two events got %v", events)
+	}
+
+	// every notifier is sent every event, even after one fails
+	for _, uid := range []string{"tester", "other"} {
+		if events := memory.Events(uid); len(events) != 1 {
+			t.Errorf("Expected one event for %s got %v", uid, events)
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(sent) != 2 {
+		t.Errorf("Expected two events got %v", sent)
+	}
+}
+
+func TestProcessReminders(t *testing.T) {
+	clock := NewFakeClock(reminderStart)
+	sent := make(chan ReminderEvent, 10)
+	notifier := NotifierFunc(func(ctx context.Context, event ReminderEvent) error {
+		sent <- event
+		return nil
+	})
+	s := newReminderStore(t, clock, WithNotifier(notifier), WithReminderInterval(time.Hour))
+	addDue(t, s, "tester", "dentist", "2026-10-19 10:00", "1h")
+
+	// the scheduler waits on the clock, moving it on by the interval runs
+	// a check
+	for _, want := range []string{"1h", "0"} {
+		deadline := time.Now().Add(time.Second)
+		for clock.Waiters() == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Hour)
+		select {
+		case event := <-sent:
+			if event.Before != want {
+				t.Errorf("Expected %s got %s", want, event.Before)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the %s reminder to be sent", want)
+		}
+	}
+}
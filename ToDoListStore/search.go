@@ -0,0 +1,336 @@
+package ToDoListStore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// search finds items by the words in their text, notes and tags. each list
+// gets an inverted index from those words to the items using them, built
+// the first time the list is searched and kept up to date as its items
+// change. an item matches when every word of the query matches one of its
+// words, exactly, as the start of it, inside it or with a typo or two, and
+// results are ranked by how closely they match. exact words are looked up
+// in the index, the starts and insides of words by a binary search of every
+// suffix of every word, and typos are only looked for in words about as
+// long as the query word.
+
+var EmptySearchErr = fmt.Errorf("nothing to search for")
+
+// SearchResult is an item that matched a search and how well it matched,
+// higher is better
+type SearchResult struct {
+	ToDoItem
+	Score float64 `json:"score"`
+}
+
+// Searcher is implemented by stores that search their lists themselves.
+// the store falls back to indexing a fetched copy of the list for ones that
+// don't.
+type Searcher interface {
+	Search(key string, query string) ([]SearchResult, error)
+}
+
+// searchIndex maps each word used on a list to the keys of the items using it
+type searchIndex struct {
+	words map[string]map[int]bool
+	// the words of each item, to take them out of words when it changes
+	items map[int][]string
+	// suffixes and lengths are built from words when a search needs them
+	// after a word was added or removed
+	stale bool
+	// every suffix of every word, sorted
+	suffixes []wordSuffix
+	// the words of each length, in runes
+	lengths map[int][]string
+}
+
+// wordSuffix is the end of word starting at some character of it
+type wordSuffix struct {
+	suffix string
+	word   string
+}
+
+func newSearchIndex(userlist map[int]ToDoItem) *searchIndex {
+	x := &searchIndex{words: make(map[string]map[int]bool), items: make(map[int][]string)}
+	for idx, v := range userlist {
+		x.add(idx, v)
+	}
+	return x
+}
+
+func (x *searchIndex) add(idx int, item ToDoItem) {
+	words := itemWords(item)
+	for _, word := range words {
+		if x.words[word] == nil {
+			x.words[word] = make(map[int]bool)
+			x.stale = true
+		}
+		x.words[word][idx] = true
+	}
+	x.items[idx] = words
+}
+
+func (x *searchIndex) remove(idx int) {
+	for _, word := range x.items[idx] {
+		delete(x.words[word], idx)
+		if len(x.words[word]) == 0 {
+			delete(x.words, word)
+			x.stale = true
+		}
+	}
+	delete(x.items, idx)
+}
+
+// search ranks the items of userlist, which x indexes, against query. each
+// result keeps its number in the whole list.
+func (x *searchIndex) search(userlist map[int]ToDoItem, query string) ([]SearchResult, error) {
+	terms := searchWords(query)
+	if len(terms) == 0 {
+		return nil, EmptySearchErr
+	}
+
+	var scores map[int]float64
+	for _, term := range terms {
+		best := x.match(term)
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for idx := range scores {
+			if best[idx] == 0 {
+				delete(scores, idx)
+			} else {
+				scores[idx] += best[idx]
+			}
+		}
+	}
+
+	keys := orderedKeys(userlist)
+
+	phrase := strings.ToLower(strings.TrimSpace(query))
+	results := make([]SearchResult, 0, len(scores))
+	for pos, idx := range keys {
+		score, found := scores[idx]
+		if !found {
+			continue
+		}
+		v := userlist[idx]
+		v.Id = pos + 1
+		text := strings.ToLower(v.Item)
+		if text == phrase {
+			score += 5
+		} else if strings.Contains(text, phrase) {
+			score += 3
+		}
+		results = append(results, SearchResult{v, score})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// build sorts the suffixes of the words and groups them by length
+func (x *searchIndex) build() {
+	x.suffixes = x.suffixes[:0]
+	x.lengths = make(map[int][]string)
+	for word := range x.words {
+		for i := range word {
+			if utf8.RuneStart(word[i]) {
+				x.suffixes = append(x.suffixes, wordSuffix{word[i:], word})
+			}
+		}
+		n := utf8.RuneCountInString(word)
+		x.lengths[n] = append(x.lengths[n], word)
+	}
+	sort.Slice(x.suffixes, func(i, j int) bool {
+		return x.suffixes[i].suffix < x.suffixes[j].suffix
+	})
+	x.stale = false
+}
+
+// match returns the score of the best match for term in each item
+func (x *searchIndex) match(term string) map[int]float64 {
+	if x.stale || x.lengths == nil {
+		x.build()
+	}
+	best := make(map[int]float64)
+	matched := make(map[string]bool)
+	credit := func(word string, score float64) {
+		matched[word] = true
+		for idx := range x.words[word] {
+			best[idx] = max(best[idx], score)
+		}
+	}
+
+	if _, found := x.words[term]; found {
+		credit(term, exactScore)
+	}
+	// the suffixes starting with term are together once sorted
+	from := sort.Search(len(x.suffixes), func(i int) bool {
+		return x.suffixes[i].suffix >= term
+	})
+	for _, v := range x.suffixes[from:] {
+		if !strings.HasPrefix(v.suffix, term) {
+			break
+		}
+		switch {
+		case v.word == term:
+		case v.suffix == v.word:
+			credit(v.word, prefixScore)
+		default:
+			credit(v.word, insideScore)
+		}
+	}
+
+	// each typo changes the length by at most one character
+	allowed := typos(term)
+	if allowed == 0 {
+		return best
+	}
+	n := utf8.RuneCountInString(term)
+	for length := n - allowed; length <= n+allowed; length++ {
+		for _, word := range x.lengths[length] {
+			if matched[word] {
+				continue
+			}
+			if distance := editDistance(term, word, allowed); distance <= allowed {
+				credit(word, typoScore(distance))
+			}
+		}
+	}
+	return best
+}
+
+// searchWords splits text into lower case words
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// itemWords returns the words an item is found by, without duplicates
+func itemWords(item ToDoItem) []string {
+	words := searchWords(item.Item + " " + item.Notes + " " + strings.Join(item.Tags, " "))
+	sort.Strings(words)
+	unique := words[:0]
+	for i, v := range words {
+		if i == 0 || v != words[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// how well a term from a query matches a word from an item
+const (
+	exactScore  = 4
+	prefixScore = 3
+	insideScore = 2
+)
+
+// typoScore is how well a term matches a word distance edits from it
+func typoScore(distance int) float64 {
+	return 1.5 - 0.5*float64(distance)
+}
+
+// typos is how many edits a term can be from a word and still match it
+func typos(term string) int {
+	switch n := len([]rune(term)); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// editDistance returns the levenshtein distance between a and b, or limit+1
+// once it is known to be more than limit
+func editDistance(a string, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		lowest := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			lowest = min(lowest, curr[j])
+		}
+		if lowest > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// searchList ranks the items on a list against query
+func (s *ToDoStore) searchList(key string, query string) ([]SearchResult, error) {
+	store := s.activeStore()
+	if searcher, ok := store.(Searcher); ok {
+		return searcher.Search(key, query)
+	}
+	userlist, err := store.Fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchIndex(userlist).search(userlist, query)
+}
+
+// SearchToDoList returns the items matching the query in KeyValue in Found,
+// best match first
+func (s *ToDoStore) SearchToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.Found, returnChannelData.Err = s.searchList(dataJob.key(), dataJob.KeyValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicSearchToDoList returns the items on a users list matching query,
+// best match first
+func (s *ToDoStore) BasicSearchToDoList(uid string, query string) ([]SearchResult, error) {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	return s.searchList(uid, query)
+}
+
+// Search ranks the items on the list stored under key against query,
+// indexing the list if it hasn't been searched before
+func (m *MemoryStore) Search(key string, query string) ([]SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.lists[key]; !found && isNamedList(key) {
+		_, name := splitListKey(key)
+		return nil, fmt.Errorf("list %q %w", name, NotFoundErr)
+	}
+	if m.index == nil {
+		m.index = make(map[string]*searchIndex)
+	}
+	x, found := m.index[key]
+	if !found {
+		x = newSearchIndex(m.lists[key])
+		m.index[key] = x
+	}
+	return x.search(m.lists[key], query)
+}
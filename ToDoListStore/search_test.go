@@ -0,0 +1,133 @@
+package ToDoListStore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func searchList(texts ...string) map[int]ToDoItem {
+	userlist := make(map[int]ToDoItem, len(texts))
+	for i, v := range texts {
+		userlist[i+1] = ToDoItem{ItemId: fmt.Sprint(i + 1), Item: v}
+	}
+	return userlist
+}
+
+// foundTexts returns the text of each result, best first
+func foundTexts(results []SearchResult) []string {
+	texts := make([]string, 0, len(results))
+	for _, v := range results {
+		texts = append(texts, v.Item)
+	}
+	return texts
+}
+
+func TestSearchRanking(t *testing.T) {
+	userlist := searchList("buttermilk pancakes", "mild cheese", "milk", "milkshake", "bread")
+	results, err := newSearchIndex(userlist).search(userlist, "milk")
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	// exact, then the start of a word, inside one and with a typo
+	want := "[milk milkshake buttermilk pancakes mild cheese]"
+	if got := fmt.Sprint(foundTexts(results)); got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score >= results[i-1].Score {
+			t.Errorf("Expected %v to score below %v", results[i], results[i-1])
+		}
+	}
+	// results keep their place in the list
+	if results[0].Id != 3 {
+		t.Errorf("Expected milk to be number 3 got %d", results[0].Id)
+	}
+}
+
+func TestSearchAllTerms(t *testing.T) {
+	userlist := searchList("call mum", "call the bank", "mum's birthday")
+	userlist[4] = ToDoItem{ItemId: "4", Item: "dentist", Notes: "call to book", Tags: []string{"health"}}
+	x := newSearchIndex(userlist)
+	for query, want := range map[string]string{
+		"call mum":     "[call mum]",
+		"mum":          "[call mum mum's birthday]",
+		"CALL":         "[call mum call the bank dentist]",
+		"book health":  "[dentist]",
+		"call dentist": "[dentist]",
+		"call nothing": "[]",
+	} {
+		results, err := x.search(userlist, query)
+		if err != nil {
+			t.Fatalf("%q: Expected nil got %v", query, err)
+		}
+		if got := fmt.Sprint(foundTexts(results)); got != want {
+			t.Errorf("%q: Expected %s got %s", query, want, got)
+		}
+	}
+	if _, err := x.search(userlist, " ,. "); !errors.Is(err, EmptySearchErr) {
+		t.Errorf("Expected EmptySearchErr got %v", err)
+	}
+}
+
+// terms of up to two letters must match as they are, up to five can have
+// one typo and longer ones two
+func TestSearchTypos(t *testing.T) {
+	for _, test := range []struct {
+		term  string
+		word  string
+		match bool
+	}{
+		{"ab", "ac", false},
+		{"ab", "abc", true},
+		{"cat", "cot", true},
+		{"cat", "cats", true},
+		{"cat", "dog", false},
+		{"bread", "braed", false},
+		{"bread", "bred", true},
+		{"banana", "bananna", true},
+		{"banana", "bnaana", true},
+		{"groceries", "grocerys", true},
+		{"groceries", "grcrys", false},
+		{"café", "cafe", true},
+	} {
+		userlist := searchList(test.word)
+		results, err := newSearchIndex(userlist).search(userlist, test.term)
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		if (len(results) == 1) != test.match {
+			t.Errorf("%q in %q: Expected a match %v got %v", test.term, test.word, test.match, results)
+		}
+	}
+}
+
+// the index a store keeps follows the changes to the list
+func TestSearchIndexFollowsChanges(t *testing.T) {
+	m := NewMemoryStore()
+	milk, bread := NewToDoItem("milk"), NewToDoItem("bread")
+	m.Add("tester", milk)
+	m.Add("tester", bread)
+	search := func(query string) string {
+		t.Helper()
+		results, err := m.Search("tester", query)
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return fmt.Sprint(foundTexts(results))
+	}
+	if got := search("milk"); got != "[milk]" {
+		t.Fatalf("Expected [milk] got %s", got)
+	}
+
+	milk.Item = "oat milk"
+	m.Update("tester", milk)
+	m.Delete("tester", bread.ItemId)
+	m.Add("tester", NewToDoItem("oatcakes"))
+	if got := search("oat"); got != "[oat milk oatcakes]" {
+		t.Errorf("Expected [oat milk oatcakes] got %s", got)
+	}
+	if got := search("bread"); got != "[]" {
+		t.Errorf("Expected [] got %s", got)
+	}
+}
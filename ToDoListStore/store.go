@@ -0,0 +1,251 @@
+package ToDoListStore
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is where the to do lists are kept. lists are identified by their
+// ListKey, which for a users default list is just the uid. the job queue
+// runs calls for different users at the same time so implementations must
+// be safe for concurrent use. calls for the same user are never concurrent.
+type Store interface {
+	// Load reads the lists from the backing storage
+	Load() error
+	// Fetch returns a copy of a list keyed in the order items were added. a
+	// named list that doesn't exist is NotFoundErr, a default list is empty.
+	Fetch(key string) (map[int]ToDoItem, error)
+	// Add appends item to a list
+	Add(key string, item ToDoItem) error
+	// Update replaces the item with the same ItemId
+	Update(key string, item ToDoItem) error
+	// Delete removes the item with the given ItemId
+	Delete(key string, itemId string) error
+	// Put replaces the item with the same ItemId, or adds item under idx,
+	// or at the end if idx is taken
+	Put(key string, idx int, item ToDoItem) error
+	// Persist writes every list to the backing storage
+	Persist() error
+	// Lists returns the names of the named lists uid owns, sorted
+	Lists(uid string) ([]string, error)
+	// Keys returns the key of every list, sorted
+	Keys() ([]string, error)
+	// CreateList adds an empty named list
+	CreateList(key string) error
+	// RenameList moves a named list and its items to newKey
+	RenameList(key string, newKey string) error
+	// DeleteList removes a named list and its items
+	DeleteList(key string) error
+}
+
+// Restorer is implemented by stores that keep backups of their data
+type Restorer interface {
+	Backups() ([]string, error)
+	Restore(backup string) error
+}
+
+const (
+	MemoryBackend  = "memory"
+	FileBackend    = "file"
+	ToDoTxtBackend = "todotxt"
+	DBBackend      = "db"
+)
+
+var UnknownBackendErr = fmt.Errorf("unknown store backend")
+var NotSupportedErr = fmt.Errorf("not supported by this store")
+
+// NewStore creates the backend named by kind. the file and todotxt
+// backends keep their data in filename, as JSON lines and in the todo.txt
+// format, the db backend in filename with a .db extension and the memory
+// backend doesn't keep it at all.
+func NewStore(kind string, filename string) (Store, error) {
+	switch kind {
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	case FileBackend, "":
+		return NewFileStore(filename), nil
+	case ToDoTxtBackend:
+		return NewToDoTxtStore(filename), nil
+	case DBBackend:
+		return NewDBStore(strings.TrimSuffix(filename, filepath.Ext(filename)) + ".db"), nil
+	}
+	return nil, fmt.Errorf("%q %w", kind, UnknownBackendErr)
+}
+
+// MemoryStore keeps lists in memory only. it is also the cache the other
+// backends load into, guarded by mu.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	lists  map[string]baseToDoList
+	logger *slog.Logger
+	// search indexes of the lists searched so far, see Search
+	index map[string]*searchIndex
+	// the number of changes made to each list, see Version. while the
+	// store is open a deleted list keeps its count, so one made again with
+	// the same name carries on from it.
+	versions map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lists: make(map[string]baseToDoList), versions: make(map[string]int64)}
+}
+
+func (m *MemoryStore) Load() error {
+	return nil
+}
+
+func (m *MemoryStore) Fetch(key string) (map[int]ToDoItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, found := m.lists[key]; !found && isNamedList(key) {
+		_, name := splitListKey(key)
+		return nil, fmt.Errorf("list %q %w", name, NotFoundErr)
+	}
+	userlist := make(map[int]ToDoItem, len(m.lists[key]))
+	for idx, v := range m.lists[key] {
+		userlist[idx] = v
+	}
+	return userlist, nil
+}
+
+func (m *MemoryStore) Add(uid string, item ToDoItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.add(uid, item)
+	return nil
+}
+
+func (m *MemoryStore) Update(uid string, item ToDoItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(uid, item)
+}
+
+func (m *MemoryStore) Delete(uid string, itemId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.remove(uid, itemId)
+}
+
+func (m *MemoryStore) Put(uid string, idx int, item ToDoItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.put(uid, m.placeItem(uid, idx, item.ItemId), item)
+	return nil
+}
+
+func (m *MemoryStore) Persist() error {
+	return nil
+}
+
+// Version returns the version of a list, 1 until it first changes and then
+// one more for every change. a named list that doesn't exist is
+// NotFoundErr.
+func (m *MemoryStore) Version(key string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, found := m.lists[key]; !found && isNamedList(key) {
+		_, name := splitListKey(key)
+		return 0, fmt.Errorf("list %q %w", name, NotFoundErr)
+	}
+	return m.versions[key] + 1, nil
+}
+
+func (m *MemoryStore) setLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// log returns the logger of the store the backend belongs to
+func (m *MemoryStore) log() *slog.Logger {
+	if m.logger == nil {
+		return Logger
+	}
+	return m.logger
+}
+
+// the lower case methods below expect the caller to hold mu
+
+// add appends item to the users list and returns its key
+func (m *MemoryStore) add(uid string, item ToDoItem) int {
+	idx := getNewKey(m.lists[uid])
+	m.put(uid, idx, item)
+	return idx
+}
+
+// placeItem returns the key Put stores an item under
+func (m *MemoryStore) placeItem(uid string, idx int, itemId string) int {
+	if found := itemIndex(m.lists[uid], itemId); found != -1 {
+		return found
+	}
+	if _, taken := m.lists[uid][idx]; taken || idx < 1 {
+		return getNewKey(m.lists[uid])
+	}
+	return idx
+}
+
+func (m *MemoryStore) put(uid string, idx int, item ToDoItem) {
+	userlist, found := m.lists[uid]
+	if !found {
+		userlist = make(baseToDoList)
+		m.lists[uid] = userlist
+	}
+	item.Id = 0
+	// items written before they had versions start at 1
+	item.Version = max(item.Version, 1)
+	userlist[idx] = item
+	m.touch(uid)
+	if x := m.index[uid]; x != nil {
+		x.remove(idx)
+		x.add(idx, item)
+	}
+}
+
+func (m *MemoryStore) update(uid string, item ToDoItem) error {
+	idx := itemIndex(m.lists[uid], item.ItemId)
+	if idx == -1 {
+		return NotFoundErr
+	}
+	m.put(uid, idx, item)
+	return nil
+}
+
+func (m *MemoryStore) remove(uid string, itemId string) error {
+	idx := itemIndex(m.lists[uid], itemId)
+	if idx == -1 {
+		return NotFoundErr
+	}
+	delete(m.lists[uid], idx)
+	if x := m.index[uid]; x != nil {
+		x.remove(idx)
+	}
+	m.touch(uid)
+	return nil
+}
+
+// touch moves the version of a list on
+func (m *MemoryStore) touch(key string) {
+	m.versions[key]++
+}
+
+// setLists replaces every list and their versions
+func (m *MemoryStore) setLists(lists map[string]baseToDoList, versions map[string]int64) {
+	m.lists = lists
+	m.versions = versions
+	m.index = nil
+}
+
+// clearList removes the list stored under key
+func (m *MemoryStore) clearList(key string) {
+	delete(m.lists, key)
+	delete(m.index, key)
+	m.touch(key)
+}
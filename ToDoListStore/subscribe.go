@@ -0,0 +1,265 @@
+package ToDoListStore
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// subscribers are sent an event for every item a change adds, updates,
+// deletes or completes. each one has its own buffered channel and events
+// are never waited on: when a subscriber falls behind and its buffer fills
+// up its SlowPolicy decides what is given up, so a stalled subscriber can't
+// hold up the data workers.
+
+// ChangeKind is what happened to an item
+type ChangeKind string
+
+const (
+	ItemAdded     ChangeKind = "added"
+	ItemUpdated   ChangeKind = "updated"
+	ItemDeleted   ChangeKind = "deleted"
+	ItemCompleted ChangeKind = "completed"
+)
+
+var SlowSubscriberErr = fmt.Errorf("subscriber fell behind")
+
+// ChangeEvent is a change to one item. Item is the item after the change,
+// or before it when it was deleted, and Before is the item before it was
+// updated or completed. Seq numbers the events the store has published, so
+// a subscriber without a filter can tell it missed some by a gap.
+type ChangeEvent struct {
+	Seq    uint64     `json:"seq"`
+	Kind   ChangeKind `json:"kind"`
+	Uid    string     `json:"uid"`
+	List   string     `json:"list"`
+	Op     string     `json:"op"`
+	Time   time.Time  `json:"time"`
+	Item   ToDoItem   `json:"item"`
+	Before *ToDoItem  `json:"before,omitempty"`
+}
+
+// ChangeFilter picks the events a subscriber is sent. List is the name of a
+// list, DefaultList for the default one, and Kinds the kinds of change, both
+// match everything when empty.
+type ChangeFilter struct {
+	List  string
+	Kinds []ChangeKind
+}
+
+func (f ChangeFilter) matches(event ChangeEvent) bool {
+	if f.List != "" && f.List != event.List {
+		return false
+	}
+	return len(f.Kinds) == 0 || slices.Contains(f.Kinds, event.Kind)
+}
+
+// SlowPolicy decides what happens to an event for a subscriber whose buffer
+// is full
+type SlowPolicy int
+
+const (
+	// DropNewest drops the event
+	DropNewest SlowPolicy = iota
+	// DropOldest drops the oldest event in the buffer to make room for it
+	DropOldest
+	// Disconnect closes the subscription, Err returns SlowSubscriberErr
+	Disconnect
+)
+
+// how many events a subscriber can fall behind by unless told otherwise
+const defaultSubscriberBuffer = 64
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*Subscription)
+
+// WithBuffer sets how many events a subscriber can fall behind by, the
+// default is 64
+func WithBuffer(size int) SubscribeOption {
+	return func(sub *Subscription) {
+		sub.buffer = max(size, 1)
+	}
+}
+
+// WithSlowPolicy sets what happens when a subscriber falls too far behind,
+// the default is DropNewest
+func WithSlowPolicy(policy SlowPolicy) SubscribeOption {
+	return func(sub *Subscription) {
+		sub.policy = policy
+	}
+}
+
+// Subscription delivers change events on C until it is closed, by Close,
+// the store closing or the subscriber falling behind under Disconnect
+type Subscription struct {
+	C <-chan ChangeEvent
+
+	uid    string
+	filter ChangeFilter
+	buffer int
+	policy SlowPolicy
+	store  *ToDoStore
+
+	// guarded by the stores subscribers mutex
+	events  chan ChangeEvent
+	closed  bool
+	dropped int
+	err     error
+}
+
+// Subscribe sends the changes to uids lists that match filter to the
+// returned subscription, or the changes to everyones lists when uid is
+// empty. the subscription should be closed once it is no longer read.
+func (s *ToDoStore) Subscribe(uid string, filter ChangeFilter, options ...SubscribeOption) *Subscription {
+	sub := &Subscription{uid: uid, filter: filter, buffer: defaultSubscriberBuffer, policy: DropNewest, store: s}
+	for _, option := range options {
+		option(sub)
+	}
+	sub.events = make(chan ChangeEvent, sub.buffer)
+	sub.C = sub.events
+
+	s.subscribers.mutex.Lock()
+	defer s.subscribers.mutex.Unlock()
+	if s.subscribers.closed {
+		sub.closeLocked(nil)
+		return sub
+	}
+	s.subscribers.subs = append(s.subscribers.subs, sub)
+	return sub
+}
+
+// Close stops the subscription and closes C
+func (sub *Subscription) Close() {
+	sub.store.subscribers.mutex.Lock()
+	defer sub.store.subscribers.mutex.Unlock()
+	sub.store.subscribers.remove(sub)
+	sub.closeLocked(nil)
+}
+
+// Dropped returns how many events the subscriber has missed by falling
+// behind
+func (sub *Subscription) Dropped() int {
+	sub.store.subscribers.mutex.Lock()
+	defer sub.store.subscribers.mutex.Unlock()
+	return sub.dropped
+}
+
+// Err returns why the store closed the subscription, nil while it is open
+// or when it was closed by Close or the store closing
+func (sub *Subscription) Err() error {
+	sub.store.subscribers.mutex.Lock()
+	defer sub.store.subscribers.mutex.Unlock()
+	return sub.err
+}
+
+func (sub *Subscription) closeLocked(err error) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	sub.err = err
+	close(sub.events)
+}
+
+// send hands an event to the subscriber without waiting, reporting false
+// once the subscriber has been disconnected
+func (sub *Subscription) send(event ChangeEvent) bool {
+	select {
+	case sub.events <- event:
+		return true
+	default:
+	}
+	sub.dropped++
+	switch sub.policy {
+	case DropOldest:
+		select {
+		case <-sub.events:
+		default:
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	case Disconnect:
+		sub.closeLocked(SlowSubscriberErr)
+		return false
+	}
+	return true
+}
+
+// subscribers are the open subscriptions to a store
+type subscribers struct {
+	mutex  sync.Mutex
+	subs   []*Subscription
+	seq    uint64
+	closed bool
+}
+
+func (b *subscribers) remove(sub *Subscription) {
+	b.subs = slices.DeleteFunc(b.subs, func(v *Subscription) bool {
+		return v == sub
+	})
+}
+
+// closeAll closes every subscription, for when the store closes
+func (b *subscribers) closeAll() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, v := range b.subs {
+		v.closeLocked(nil)
+	}
+	b.subs = nil
+	b.closed = true
+}
+
+// changeEvents turns what a change did to a users lists into events
+func changeEvents(uid string, entry historyEntry) []ChangeEvent {
+	events := make([]ChangeEvent, 0, len(entry.Items))
+	for _, v := range entry.Items {
+		_, name := splitListKey(v.Key)
+		if name == "" {
+			name = DefaultList
+		}
+		event := ChangeEvent{Uid: uid, List: name, Op: entry.Op, Time: entry.Time}
+		switch {
+		case v.Before == nil:
+			event.Kind, event.Item = ItemAdded, *v.After
+		case v.After == nil:
+			event.Kind, event.Item = ItemDeleted, *v.Before
+		case v.After.Done && !v.Before.Done, v.After.Completed.After(v.Before.Completed):
+			// a recurring item is open again by the time it is completed
+			event.Kind, event.Item, event.Before = ItemCompleted, *v.After, v.Before
+		default:
+			event.Kind, event.Item, event.Before = ItemUpdated, *v.After, v.Before
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// publish sends what a change did to a users lists to the subscribers
+func (s *ToDoStore) publish(uid string, entry historyEntry) {
+	s.subscribers.mutex.Lock()
+	defer s.subscribers.mutex.Unlock()
+	if len(s.subscribers.subs) == 0 {
+		return
+	}
+
+	gone := make([]*Subscription, 0)
+	for _, event := range changeEvents(uid, entry) {
+		s.subscribers.seq++
+		event.Seq = s.subscribers.seq
+		for _, sub := range s.subscribers.subs {
+			if sub.closed || (sub.uid != "" && sub.uid != uid) || !sub.filter.matches(event) {
+				continue
+			}
+			if !sub.send(event) {
+				gone = append(gone, sub)
+			}
+		}
+	}
+	for _, v := range gone {
+		s.subscribers.remove(v)
+	}
+}
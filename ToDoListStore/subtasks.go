@@ -0,0 +1,338 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// an item can be a sub-task of another item on the same list, to any depth.
+// lists are still stored flat, each sub-task naming its parent by ItemId,
+// and Tree turns them into a hierarchy for display. a parent is only done
+// when all of its sub-tasks are, so reopening or adding a sub-task reopens
+// the items above it. what happens to the sub-tasks when their parent is
+// completed, moved or deleted is up to the stores ChildPolicy.
+
+// ChildPolicy decides what completing, moving or deleting an item with
+// sub-tasks does to them
+type ChildPolicy int
+
+const (
+	// CascadeChildren completes, moves or deletes the sub-tasks along with
+	// the item
+	CascadeChildren ChildPolicy = iota
+	// BlockOnChildren refuses to complete an item with open sub-tasks or
+	// move or delete one with any sub-tasks
+	BlockOnChildren
+)
+
+var ChildrenErr = fmt.Errorf("blocked by sub-tasks")
+var CycleErr = fmt.Errorf("an item can't be moved under itself")
+var UnknownChildPolicyErr = fmt.Errorf("unknown sub-task policy")
+
+// ParseChildPolicy reads the policy named by "cascade" or "block"
+func ParseChildPolicy(name string) (ChildPolicy, error) {
+	switch name {
+	case "cascade", "":
+		return CascadeChildren, nil
+	case "block":
+		return BlockOnChildren, nil
+	}
+	return CascadeChildren, fmt.Errorf("%q %w", name, UnknownChildPolicyErr)
+}
+
+// WithChildPolicy sets what happens to sub-tasks when their parent is
+// completed, moved or deleted, the default is CascadeChildren
+func WithChildPolicy(policy ChildPolicy) Option {
+	return func(s *ToDoStore) error {
+		s.childPolicy = policy
+		return nil
+	}
+}
+
+// SetChildPolicy sets what happens to sub-tasks when their parent is
+// completed, moved or deleted. call it before queueing any jobs.
+func (s *ToDoStore) SetChildPolicy(policy ChildPolicy) {
+	s.childPolicy = policy
+}
+
+// ToDoNode is an item along with its sub-tasks
+type ToDoNode struct {
+	ToDoItem
+	Children []ToDoNode `json:"children,omitempty"`
+}
+
+// Tree arranges items, as returned by SortedArray, into a hierarchy. a
+// sub-task whose parent isn't among items is shown at the top level.
+func Tree(items []ToDoItem) []ToDoNode {
+	present := make(map[string]bool, len(items))
+	for _, v := range items {
+		present[v.ItemId] = true
+	}
+	roots := make([]ToDoItem, 0)
+	children := make(map[string][]ToDoItem)
+	for _, v := range items {
+		if v.Parent != "" && present[v.Parent] {
+			children[v.Parent] = append(children[v.Parent], v)
+		} else {
+			roots = append(roots, v)
+		}
+	}
+
+	visited := make(map[string]bool, len(items))
+	var build func(items []ToDoItem) []ToDoNode
+	build = func(items []ToDoItem) []ToDoNode {
+		nodes := make([]ToDoNode, 0, len(items))
+		for _, v := range items {
+			if visited[v.ItemId] {
+				continue
+			}
+			visited[v.ItemId] = true
+			nodes = append(nodes, ToDoNode{v, build(children[v.ItemId])})
+		}
+		return nodes
+	}
+	tree := build(roots)
+	// items that are their own ancestor can't be reached from the top
+	for _, v := range items {
+		if !visited[v.ItemId] {
+			tree = append(tree, build([]ToDoItem{v})...)
+		}
+	}
+	return tree
+}
+
+// descendants returns the keys of every item below itemId
+func descendants(userlist map[int]ToDoItem, itemId string) []int {
+	found := make([]int, 0)
+	seen := map[string]bool{itemId: true}
+	parents := []string{itemId}
+	for len(parents) > 0 {
+		next := make([]string, 0)
+		for idx, v := range userlist {
+			if v.Parent != "" && !seen[v.ItemId] && contains(parents, v.Parent) {
+				seen[v.ItemId] = true
+				found = append(found, idx)
+				next = append(next, v.ItemId)
+			}
+		}
+		parents = next
+	}
+	return found
+}
+
+// ancestors returns the keys of every item above the item at idx
+func ancestors(userlist map[int]ToDoItem, idx int) []int {
+	found := make([]int, 0)
+	seen := map[int]bool{idx: true}
+	for parent := userlist[idx].Parent; parent != ""; {
+		pidx := itemIndex(userlist, parent)
+		if pidx == -1 || seen[pidx] {
+			break
+		}
+		seen[pidx] = true
+		found = append(found, pidx)
+		parent = userlist[pidx].Parent
+	}
+	return found
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// siblingExists reports whether an item under parent already has text
+func siblingExists(userlist map[int]ToDoItem, parent string, text string) bool {
+	for _, v := range userlist {
+		if v.Parent == parent && v.Item == text {
+			return true
+		}
+	}
+	return false
+}
+
+// updateItems saves the items at keys after applying change to each one,
+// marking them updated at now
+func updateItems(store Store, key string, userlist map[int]ToDoItem, keys []int, now time.Time, change func(todo *ToDoItem)) error {
+	for _, idx := range keys {
+		todo := userlist[idx]
+		change(&todo)
+		todo.Updated = now
+		todo.Version++
+		if err := store.Update(key, todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reopenAncestors reopens the done items above the item at idx
+func reopenAncestors(store Store, key string, userlist map[int]ToDoItem, idx int, now time.Time) error {
+	done := make([]int, 0)
+	for _, v := range ancestors(userlist, idx) {
+		if userlist[v].Done {
+			done = append(done, v)
+		}
+	}
+	return updateItems(store, key, userlist, done, now, func(todo *ToDoItem) {
+		todo.Done = false
+		todo.Completed = time.Time{}
+	})
+}
+
+// setDone completes or reopens the item itemKey refers to. completing it
+// completes its open sub-tasks or is blocked by them, reopening it reopens
+// the items above it. completing a recurring item moves it on to its next
+// occurrence instead.
+func (s *ToDoStore) setDone(key string, itemKey string, done bool) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	idx := findItem(userlist, itemKey)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+
+	changed := []int{idx}
+	if done {
+		for _, v := range descendants(userlist, userlist[idx].ItemId) {
+			if !userlist[v].Done {
+				changed = append(changed, v)
+			}
+		}
+		if len(changed) > 1 && s.childPolicy == BlockOnChildren {
+			return nil, fmt.Errorf("%q has %d open sub-tasks %w", userlist[idx].Item, len(changed)-1, ChildrenErr)
+		}
+		if userlist[idx].Repeat != "" {
+			if err := completeRecurring(store, key, userlist, idx, s.now()); err != nil {
+				return nil, err
+			}
+			return store.Fetch(key)
+		}
+	} else if err := reopenAncestors(store, key, userlist, idx, s.now()); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := updateItems(store, key, userlist, changed, now, func(todo *ToDoItem) {
+		todo.Done = done
+		if done {
+			todo.Completed = now
+		} else {
+			todo.Completed = time.Time{}
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return store.Fetch(key)
+}
+
+// moveItem makes the item itemKey refers to a sub-task of the item
+// parentKey refers to, or a top level item when parentKey is empty. its
+// sub-tasks move with it or, depending on the ChildPolicy, stop it moving.
+func (s *ToDoStore) moveItem(key string, itemKey string, parentKey string) (map[int]ToDoItem, error) {
+	store := s.activeStore()
+	userlist, err := store.Fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	idx := findItem(userlist, itemKey)
+	if idx == -1 {
+		return nil, NotFoundErr
+	}
+
+	parent := ""
+	if parentKey != "" {
+		pidx := findItem(userlist, parentKey)
+		if pidx == -1 {
+			return nil, fmt.Errorf("parent %w", NotFoundErr)
+		}
+		parent = userlist[pidx].ItemId
+		if pidx == idx || contains(idsOf(userlist, descendants(userlist, userlist[idx].ItemId)), parent) {
+			return nil, CycleErr
+		}
+	}
+	if children := descendants(userlist, userlist[idx].ItemId); len(children) > 0 && s.childPolicy == BlockOnChildren {
+		return nil, fmt.Errorf("%q has %d sub-tasks %w", userlist[idx].Item, len(children), ChildrenErr)
+	}
+
+	now := s.now()
+	if err := updateItems(store, key, userlist, []int{idx}, now, func(todo *ToDoItem) {
+		todo.Parent = parent
+	}); err != nil {
+		return nil, err
+	}
+	// userlist is our own copy, bring it up to date with the move
+	moved := userlist[idx]
+	moved.Parent = parent
+	userlist[idx] = moved
+	if !subtreeDone(userlist, idx) {
+		if err := reopenAncestors(store, key, userlist, idx, now); err != nil {
+			return nil, err
+		}
+	}
+	return store.Fetch(key)
+}
+
+// subtreeDone reports whether the item at idx and all its sub-tasks are done
+func subtreeDone(userlist map[int]ToDoItem, idx int) bool {
+	if !userlist[idx].Done {
+		return false
+	}
+	for _, v := range descendants(userlist, userlist[idx].ItemId) {
+		if !userlist[v].Done {
+			return false
+		}
+	}
+	return true
+}
+
+func idsOf(userlist map[int]ToDoItem, keys []int) []string {
+	ids := make([]string, 0, len(keys))
+	for _, v := range keys {
+		ids = append(ids, userlist[v].ItemId)
+	}
+	return ids
+}
+
+// MoveToDoItem makes the item in KeyValue a sub-task of the item in
+// AltValue, or a top level item when AltValue is empty
+func (s *ToDoStore) MoveToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.moveItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+// BasicAddSubTask adds item as a sub-task of the item parent refers to
+func (s *ToDoStore) BasicAddSubTask(uid string, parent string, item string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "add", func() error {
+		_, err := s.addItem(uid, item, parent, "")
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicMoveToDoItem(uid string, item string, parent string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "move", func() error {
+		_, err := s.moveItem(uid, item, parent)
+		return err
+	})
+}
@@ -0,0 +1,85 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// newSubtaskStore returns a store holding a tree of items, two sub-tasks
+// under plan and one under draft, along with home to move them to
+func newSubtaskStore(t *testing.T, policy ChildPolicy) *ToDoStore {
+	t.Helper()
+	s, err := New(WithStore(NewMemoryStore()), WithLogFile(os.DevNull), WithChildPolicy(policy))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	for _, job := range []DataStoreJob{
+		{JobType: AddData, KeyValue: "plan"},
+		{JobType: AddData, KeyValue: "draft", AltValue: "plan"},
+		{JobType: AddData, KeyValue: "outline", AltValue: "draft"},
+		{JobType: AddData, KeyValue: "review", AltValue: "plan"},
+		{JobType: AddData, KeyValue: "home"},
+	} {
+		job.Uid = "tester"
+		if _, err := s.Do(context.Background(), job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	return s
+}
+
+// parents returns the text of the parent of each item, by its text
+func parents(userlist map[int]ToDoItem) map[string]string {
+	ids := make(map[string]string, len(userlist))
+	for _, v := range userlist {
+		ids[v.ItemId] = v.Item
+	}
+	out := make(map[string]string, len(userlist))
+	for _, v := range userlist {
+		out[v.Item] = ids[v.Parent]
+	}
+	return out
+}
+
+func TestMoveCascadesToChildren(t *testing.T) {
+	s := newSubtaskStore(t, CascadeChildren)
+	ret, err := s.Do(context.Background(), DataStoreJob{Uid: "tester", JobType: MoveData, KeyValue: "draft", AltValue: "home"})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	got := parents(ret.List)
+	// the sub-task of draft goes with it
+	for item, want := range map[string]string{"plan": "", "draft": "home", "outline": "draft", "review": "plan", "home": ""} {
+		if got[item] != want {
+			t.Errorf("Expected %s under %q got %q", item, want, got[item])
+		}
+	}
+	var tree []ToDoNode
+	for _, v := range Tree(SortedMap(ret.List)) {
+		if v.Item == "home" {
+			tree = v.Children
+		}
+	}
+	if len(tree) != 1 || tree[0].Item != "draft" || len(tree[0].Children) != 1 || tree[0].Children[0].Item != "outline" {
+		t.Errorf("Expected draft and outline under home got %v", tree)
+	}
+}
+
+func TestMoveBlockedByChildren(t *testing.T) {
+	s := newSubtaskStore(t, BlockOnChildren)
+	ctx := context.Background()
+	if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: MoveData, KeyValue: "draft", AltValue: "home"}); !errors.Is(err, ChildrenErr) {
+		t.Errorf("Expected ChildrenErr got %v", err)
+	}
+	// a sub-task without sub-tasks of its own still moves
+	ret, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: MoveData, KeyValue: "outline"})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	if got := parents(ret.List); got["draft"] != "plan" || got["outline"] != "" {
+		t.Errorf("Expected only outline to move got %v", got)
+	}
+}
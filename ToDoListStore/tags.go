@@ -0,0 +1,156 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tags are short lower case labels, kept sorted on each item. a leading #
+// is dropped so "#Work" and "work" are the same tag.
+
+var InvalidTagErr = fmt.Errorf("invalid tag")
+
+// NormaliseTag returns tag in the form it is stored in
+func NormaliseTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || strings.ContainsAny(tag, " \t\r\n,#") {
+		return "", fmt.Errorf("%q %w", tag, InvalidTagErr)
+	}
+	return tag, nil
+}
+
+// HasTag reports whether the item carries tag
+func (t ToDoItem) HasTag(tag string) bool {
+	for _, v := range t.Tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ToDoItem) addTag(tag string) {
+	if t.HasTag(tag) {
+		return
+	}
+	t.Tags = append(append([]string(nil), t.Tags...), tag)
+	sort.Strings(t.Tags)
+}
+
+func (t *ToDoItem) removeTag(tag string) {
+	tags := make([]string, 0, len(t.Tags))
+	for _, v := range t.Tags {
+		if v != tag {
+			tags = append(tags, v)
+		}
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	t.Tags = tags
+}
+
+// filterTag returns the items in userlist that carry tag. each one keeps
+// its number in the whole list so it can still be used to refer to it.
+func filterTag(userlist map[int]ToDoItem, tag string) map[int]ToDoItem {
+	filtered := make(map[int]ToDoItem)
+	for pos, idx := range orderedKeys(userlist) {
+		item := userlist[idx]
+		if item.HasTag(tag) {
+			item.Id = pos + 1
+			filtered[idx] = item
+		}
+	}
+	return filtered
+}
+
+// tagItem adds or removes a tag on the item key refers to, see findItem
+func (s *ToDoStore) tagItem(uid string, key string, tag string, add bool) (map[int]ToDoItem, error) {
+	tag, err := NormaliseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeItem(uid, key, func(todo *ToDoItem) {
+		if add {
+			todo.addTag(tag)
+		} else {
+			todo.removeTag(tag)
+		}
+	})
+}
+
+// filterList returns the items on a users list carrying tag
+func (s *ToDoStore) filterList(uid string, tag string) (map[int]ToDoItem, error) {
+	tag, err := NormaliseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	userlist, err := s.activeStore().Fetch(uid)
+	if err != nil {
+		return nil, err
+	}
+	return filterTag(userlist, tag), nil
+}
+
+// TagToDoItem adds the tag in AltValue to the item in KeyValue
+func (s *ToDoStore) TagToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.tagItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue, true)
+	s.reply(dataJob, returnChannelData)
+}
+
+// UntagToDoItem removes the tag in AltValue from the item in KeyValue
+func (s *ToDoStore) UntagToDoItem(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.tagItem(dataJob.key(), dataJob.KeyValue, dataJob.AltValue, false)
+	s.reply(dataJob, returnChannelData)
+}
+
+// FilterToDoList fetches the items carrying the tag in KeyValue
+func (s *ToDoStore) FilterToDoList(dataJob DataStoreJob) {
+	defer close(dataJob.ReturnChannel)
+	returnChannelData := ReturnChannelData{}
+	returnChannelData.List, returnChannelData.Err = s.filterList(dataJob.key(), dataJob.KeyValue)
+	s.reply(dataJob, returnChannelData)
+}
+
+func (s *ToDoStore) BasicTagToDoItem(uid string, item string, tag string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "tag", func() error {
+		_, err := s.tagItem(uid, item, tag, true)
+		return err
+	})
+}
+
+func (s *ToDoStore) BasicUntagToDoItem(uid string, item string, tag string) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	return s.recordChange(context.Background(), uid, "untag", func() error {
+		_, err := s.tagItem(uid, item, tag, false)
+		return err
+	})
+}
+
+// BasicFilterToDoList returns the items on a users list carrying tag
+func (s *ToDoStore) BasicFilterToDoList(uid string, tag string) (map[int]ToDoItem, error) {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	return s.filterList(uid, tag)
+}
@@ -0,0 +1,418 @@
+package ToDoListStore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// the todo.txt format, see todotxt.org, is one item a line
+//
+//	x 2026-10-17 2026-10-01 call mum +family @phone due:2026-10-18
+//	(A) 2026-10-01 buy milk @shop id:0192... tag:errands
+//
+// an x first marks a done item and is followed by when it was completed,
+// an (A) first gives the priority of one not done. then comes the date it
+// was created and its text. words in the text starting with + are its
+// projects and with @ its contexts, words like key:value are extensions
+// and aren't part of the text. the fields the format has no place for are
+// kept in extensions, id, parent, due, rec, remind, reminded, tag, note,
+// ver for the version, rank for the place in manual order and pri for the
+// priority of a done item. values are written with % and
+// spaces escaped like in a url. extensions this store doesn't know, or
+// can't read the value of, are kept in Extensions and written back as they
+// were. the text is escaped the same way where it wouldn't read back as it
+// was, see toDoTxtWords.
+
+// extensions the todotxt backend adds for the owner and list of an item
+const (
+	todoTxtUid  = "uid"
+	todoTxtList = "list"
+)
+
+const todoTxtDate = "2006-01-02"
+
+// todoTxtEscaper escapes extension values so they stay one word
+var todoTxtEscaper = strings.NewReplacer("%", "%25", " ", "%20", "\t", "%09", "\r", "%0D", "\n", "%0A")
+
+func escapeToDoTxt(value string) string {
+	return todoTxtEscaper.Replace(value)
+}
+
+func unescapeToDoTxt(value string) string {
+	if v, err := url.PathUnescape(value); err == nil {
+		return v
+	}
+	return value
+}
+
+// parseToDoTxtDate reads a yyyy-mm-dd date, reporting false for a word
+// that isn't one
+func parseToDoTxtDate(word string) (time.Time, bool) {
+	t, err := time.ParseInLocation(todoTxtDate, word, time.Local)
+	return t, err == nil
+}
+
+// isToDoTxtPriority reports whether word is a priority like (A)
+func isToDoTxtPriority(word string) bool {
+	return len(word) == 3 && word[0] == '(' && word[2] == ')' && word[1] >= 'A' && word[1] <= 'Z'
+}
+
+// extension splits a word like key:value, reporting false for one that
+// isn't an extension, like a time or a url
+func extension(word string) (string, string, bool) {
+	key, value, found := strings.Cut(word, ":")
+	if !found || key == "" || value == "" || strings.HasPrefix(value, "//") {
+		return "", "", false
+	}
+	if !unicode.IsLetter([]rune(key)[0]) {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// parseToDoTxt reads an item from a line of todo.txt
+func parseToDoTxt(line string) ToDoItem {
+	item := NewToDoItem("")
+	words := strings.Fields(line)
+	if len(words) > 0 && words[0] == "x" {
+		item.Done = true
+		words = words[1:]
+		if t, ok := parseToDoTxtDate(firstWord(words)); ok {
+			item.Completed = t
+			words = words[1:]
+		}
+	} else if p := firstWord(words); isToDoTxtPriority(p) {
+		item.Priority = p[1:2]
+		words = words[1:]
+	}
+	if t, ok := parseToDoTxtDate(firstWord(words)); ok {
+		item.Created = t
+		words = words[1:]
+	} else if !item.Completed.IsZero() {
+		// done before it was added here
+		item.Created = item.Completed
+	}
+
+	text := make([]string, 0, len(words))
+	for _, v := range words {
+		key, value, ok := extension(v)
+		if !ok {
+			text = append(text, unescapeToDoTxt(v))
+			if len(v) > 1 && v[0] == '+' && !slices.Contains(item.Projects, v[1:]) {
+				item.Projects = append(item.Projects, v[1:])
+			}
+			if len(v) > 1 && v[0] == '@' && !slices.Contains(item.Contexts, v[1:]) {
+				item.Contexts = append(item.Contexts, v[1:])
+			}
+			continue
+		}
+		if !item.setExtension(key, unescapeToDoTxt(value)) {
+			if item.Extensions == nil {
+				item.Extensions = make(map[string]string)
+			}
+			item.Extensions[key] = unescapeToDoTxt(value)
+		}
+	}
+	item.Item = strings.Join(text, " ")
+	item.Updated = item.Created
+	if item.Completed.After(item.Updated) {
+		item.Updated = item.Completed
+	}
+	return item
+}
+
+func firstWord(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return words[0]
+}
+
+// setExtension sets the field an extension holds, reporting false when
+// it isn't one or its value can't be used
+func (t *ToDoItem) setExtension(key string, value string) bool {
+	switch key {
+	case "id":
+		t.ItemId = value
+	case "parent":
+		t.Parent = value
+	case "due":
+		due, err := ParseDue(value, time.Now())
+		if err != nil {
+			return false
+		}
+		t.Due = due
+	case "rec":
+		if _, err := ParseRecurrence(value, time.Now()); err != nil {
+			return false
+		}
+		t.Repeat = value
+	case "remind":
+		reminders := strings.Split(value, ",")
+		for _, v := range reminders {
+			if _, err := ParseReminder(v); err != nil {
+				return false
+			}
+		}
+		t.Reminders = reminders
+	case "reminded":
+		reminded, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false
+		}
+		t.Reminded = reminded
+	case "tag":
+		tag, err := NormaliseTag(value)
+		if err != nil {
+			return false
+		}
+		t.addTag(tag)
+	case "note":
+		t.Notes = value
+	case "ver":
+		version, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || version < 1 {
+			return false
+		}
+		t.Version = version
+	case "rank":
+		rank, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || rank == 0 {
+			return false
+		}
+		t.Rank = rank
+	case "pri":
+		p, err := ParsePriority(value)
+		if err != nil || p == "" {
+			return false
+		}
+		t.Priority = p
+	default:
+		return false
+	}
+	return true
+}
+
+// formatToDoTxt writes item as a line of todo.txt
+func formatToDoTxt(item ToDoItem) string {
+	words := make([]string, 0)
+	if item.Done {
+		completed := item.Completed
+		if completed.IsZero() {
+			completed = item.Updated
+		}
+		words = append(words, "x", completed.Local().Format(todoTxtDate))
+	} else if item.Priority != "" {
+		words = append(words, "("+item.Priority+")")
+	}
+	if !item.Created.IsZero() {
+		words = append(words, item.Created.Local().Format(todoTxtDate))
+	}
+	text := toDoTxtWords(item.Item, item.Created.IsZero())
+	words = append(words, text...)
+	for _, v := range item.Projects {
+		if !slices.Contains(text, "+"+v) {
+			words = append(words, "+"+v)
+		}
+	}
+	for _, v := range item.Contexts {
+		if !slices.Contains(text, "@"+v) {
+			words = append(words, "@"+v)
+		}
+	}
+
+	written := make(map[string]bool)
+	ext := func(key string, value string) {
+		words = append(words, key+":"+escapeToDoTxt(value))
+		written[key] = true
+	}
+	ext("id", item.ItemId)
+	if item.Parent != "" {
+		ext("parent", item.Parent)
+	}
+	if !item.Due.IsZero() {
+		due := item.Due.Local()
+		if due.Hour() == 0 && due.Minute() == 0 {
+			ext("due", due.Format(todoTxtDate))
+		} else {
+			ext("due", due.Format("2006-01-02T15:04"))
+		}
+	}
+	if item.Repeat != "" {
+		ext("rec", item.Repeat)
+	}
+	if len(item.Reminders) > 0 {
+		ext("remind", strings.Join(item.Reminders, ","))
+	}
+	if !item.Reminded.IsZero() {
+		ext("reminded", item.Reminded.Format(time.RFC3339))
+	}
+	for _, v := range item.Tags {
+		ext("tag", v)
+	}
+	if item.Notes != "" {
+		ext("note", item.Notes)
+	}
+	if item.Version > 0 {
+		ext("ver", strconv.FormatInt(item.Version, 10))
+	}
+	if item.Rank != 0 {
+		ext("rank", strconv.FormatInt(item.Rank, 10))
+	}
+	if item.Done && item.Priority != "" {
+		ext("pri", item.Priority)
+	}
+	for _, k := range slices.Sorted(maps.Keys(item.Extensions)) {
+		if !written[k] {
+			ext(k, item.Extensions[k])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// toDoTxtWords splits the text of an item into the words written for it,
+// escaped so that it reads back as it was. % and any whitespace but a
+// single space between two words are escaped like in a url, as is the colon
+// of a word that would be read as an extension. when the text comes first
+// on the line, the first letter of a word that would be read as the mark of
+// a done item, a priority or a date is escaped too.
+func toDoTxtWords(text string, first bool) []string {
+	if text == "" {
+		return nil
+	}
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '%':
+			b.WriteString("%25")
+		case r == ' ' && i > 0 && i < len(runes)-1 && runes[i-1] != ' ' && runes[i+1] != ' ':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteString(url.PathEscape(string(r)))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	words := strings.Split(b.String(), " ")
+	for i, v := range words {
+		if _, _, ok := extension(v); ok {
+			words[i] = strings.ReplaceAll(v, ":", "%3A")
+		}
+	}
+	if _, date := parseToDoTxtDate(words[0]); first && (words[0] == "x" || isToDoTxtPriority(words[0]) || date) {
+		words[0] = fmt.Sprintf("%%%02X", words[0][0]) + words[0][1:]
+	}
+	return words
+}
+
+// ReadToDoTxt reads the items in a todo.txt file, in the order they are in
+// it. items without an id are given a new one.
+func ReadToDoTxt(r io.Reader) ([]ToDoItem, error) {
+	items := make([]ToDoItem, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		items = append(items, parseToDoTxt(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// WriteToDoTxt writes items as a todo.txt file, one line each in the order
+// given
+func WriteToDoTxt(w io.Writer, items []ToDoItem) error {
+	for _, v := range items {
+		if _, err := fmt.Fprintln(w, formatToDoTxt(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readToDoTxtFile reads a todo file kept in the todo.txt format, the owner
+// and list of each item in its uid and list extensions. list versions and
+// empty named lists aren't kept in it, the versions start again from 1. a
+// file in the JSON lines format is read as that and reported as legacy so
+// it is rewritten.
+func readToDoTxtFile(r io.Reader) (map[string]baseToDoList, map[string]int64, bool, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	first, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	var h fileHeader
+	if json.Unmarshal(first, &h) == nil && h.Format == fileFormatName {
+		lists, versions, _, err := readToDoFile(bytes.NewReader(data))
+		return lists, versions, true, err
+	}
+
+	items, err := ReadToDoTxt(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	lists := make(map[string]baseToDoList)
+	for _, v := range items {
+		key := ListKey(v.Extensions[todoTxtUid], v.Extensions[todoTxtList])
+		delete(v.Extensions, todoTxtUid)
+		delete(v.Extensions, todoTxtList)
+		if len(v.Extensions) == 0 {
+			v.Extensions = nil
+		}
+		userlist, found := lists[key]
+		if !found {
+			userlist = make(baseToDoList)
+			lists[key] = userlist
+		}
+		userlist[getNewKey(userlist)] = v
+	}
+	return lists, make(map[string]int64), false, nil
+}
+
+// writeToDoTxtFile writes every list in the todo.txt format, each in
+// manual order
+func writeToDoTxtFile(w io.Writer, lists map[string]baseToDoList) error {
+	keys := make([]string, 0, len(lists))
+	for key := range lists {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		uid, name := splitListKey(key)
+		items := make([]ToDoItem, 0, len(lists[key]))
+		for _, idx := range orderedKeys(lists[key]) {
+			item := lists[key][idx]
+			item.Extensions = maps.Clone(item.Extensions)
+			if item.Extensions == nil {
+				item.Extensions = make(map[string]string)
+			}
+			if uid != "" {
+				item.Extensions[todoTxtUid] = uid
+			}
+			if name != "" {
+				item.Extensions[todoTxtList] = name
+			}
+			items = append(items, item)
+		}
+		if err := WriteToDoTxt(w, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
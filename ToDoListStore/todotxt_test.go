@@ -0,0 +1,164 @@
+package ToDoListStore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// text that looks like the other parts of a todo.txt line reads back as
+// the text it was
+func TestToDoTxtTextRoundTrip(t *testing.T) {
+	texts := []string{
+		"buy milk",
+		"call mum +family @phone",
+		"note: ring the bank",
+		"see note:friday",
+		"meet at 10:30",
+		"move due:tomorrow to next week",
+		"id:x marks it",
+		"rec:daily isn't a rule here",
+		"http://example.com and mailto:someone",
+		"x marks the spot",
+		"(A) is a grade",
+		"2026-10-01 was a monday",
+		"two  spaces",
+		" leading space",
+		"trailing space ",
+		"   ",
+		"tab\there",
+		"line\nbreak",
+		"no break",
+		"50% off, not %20",
+		"",
+	}
+	created := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	for _, text := range texts {
+		for _, item := range []ToDoItem{
+			{ItemId: "1", Item: text},
+			{ItemId: "1", Item: text, Created: created},
+			{ItemId: "1", Item: text, Priority: "B"},
+			{ItemId: "1", Item: text, Done: true, Completed: created},
+		} {
+			line := formatToDoTxt(item)
+			got := parseToDoTxt(line)
+			if got.Item != item.Item {
+				t.Errorf("Expected %q got %q from %q", item.Item, got.Item, line)
+			}
+			if got.Notes != "" || !got.Due.IsZero() || got.Repeat != "" || got.ItemId != "1" || got.Done != item.Done || got.Priority != item.Priority {
+				t.Errorf("Expected only the text to be read from %q got %+v", line, got)
+			}
+			if len(got.Extensions) != 0 {
+				t.Errorf("Expected no extensions from %q got %v", line, got.Extensions)
+			}
+		}
+	}
+}
+
+// text written by other tools is left alone
+func TestToDoTxtTextFromOtherTools(t *testing.T) {
+	for line, want := range map[string]string{
+		"buy milk +shop":                   "buy milk +shop",
+		"2026-10-01 50% off":               "50% off",
+		"(A)  call    mum  due:2026-10-18": "call mum",
+		"x 2026-10-17 2026-10-01 done it":  "done it",
+	} {
+		if got := parseToDoTxt(line).Item; got != want {
+			t.Errorf("Expected %q got %q from %q", want, got, line)
+		}
+	}
+}
+
+func TestToDoTxtFieldsRoundTrip(t *testing.T) {
+	due := time.Date(2026, 10, 21, 9, 30, 0, 0, time.Local)
+	item := ToDoItem{
+		ItemId:    "0192",
+		Item:      "see the dentist",
+		Created:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local),
+		Notes:     "bring the forms: all  three",
+		Tags:      []string{"health"},
+		Parent:    "0191",
+		Repeat:    "every 3 days",
+		Due:       due,
+		Reminders: []string{"1d", "30m"},
+		Priority:  "A",
+		Version:   3,
+		Rank:      -42,
+	}
+	line := formatToDoTxt(item)
+	got := parseToDoTxt(line)
+	if got.Item != item.Item || got.Notes != item.Notes || got.Parent != item.Parent || got.Repeat != item.Repeat || got.Priority != item.Priority {
+		t.Errorf("Expected %+v got %+v from %q", item, got, line)
+	}
+	if !got.Due.Equal(due) || !got.Created.Equal(item.Created) {
+		t.Errorf("Expected due %v created %v got %v %v from %q", due, item.Created, got.Due, got.Created, line)
+	}
+	if got.Version != item.Version || got.Rank != item.Rank {
+		t.Errorf("Expected version %d rank %d got %d %d from %q", item.Version, item.Rank, got.Version, got.Rank, line)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "health" || len(got.Reminders) != 2 {
+		t.Errorf("Expected tags and reminders got %v %v from %q", got.Tags, got.Reminders, line)
+	}
+}
+
+// a todo.txt store keeps the versions and manual order of its items
+func TestToDoTxtStoreKeepsVersionAndRank(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "todo.txt")
+	ctx := context.Background()
+	open := func() *ToDoStore {
+		s, err := New(WithStore(NewToDoTxtStore(filename)), WithLogFile(os.DevNull))
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: LoadData}); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return s
+	}
+
+	s := open()
+	for _, job := range []DataStoreJob{
+		{Uid: "tester", JobType: AddData, KeyValue: "milk"},
+		{Uid: "tester", JobType: AddData, KeyValue: "bread"},
+		{Uid: "tester", JobType: AddData, KeyValue: "eggs"},
+		{Uid: "tester", JobType: UpdateData, KeyValue: "milk", AltValue: "oat milk"},
+		{Uid: "tester", JobType: ReorderData, KeyValue: "eggs", AltValue: "first"},
+		{Uid: "tester", JobType: StoreData},
+	} {
+		if _, err := s.Do(ctx, job); err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+	}
+	ret, _ := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: FetchData})
+	s.Close()
+	// the journal would put them back without the file
+	os.Remove(journalName(filename))
+
+	s = open()
+	defer s.Close()
+	reloaded, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: FetchData})
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	want, got := make(map[string]ToDoItem), make(map[string]ToDoItem)
+	for _, v := range ret.List {
+		want[v.Item] = v
+	}
+	for _, v := range reloaded.List {
+		got[v.Item] = v
+	}
+	for text, v := range want {
+		if got[text].Version != v.Version || got[text].Rank != v.Rank {
+			t.Errorf("Expected %q at version %d rank %d got %d %d", text, v.Version, v.Rank, got[text].Version, got[text].Rank)
+		}
+	}
+	order := ""
+	for _, idx := range orderedKeys(reloaded.List) {
+		order += reloaded.List[idx].Item + ","
+	}
+	if order != "eggs,oat milk,bread," {
+		t.Errorf("Expected eggs,oat milk,bread, got %s", order)
+	}
+}
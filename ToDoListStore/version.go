@@ -0,0 +1,131 @@
+package ToDoListStore
+
+import (
+	"context"
+	"fmt"
+)
+
+// items and lists have versions that only ever go up, an item when it
+// changes and a list when anything in it does. a change can say which
+// versions it was made against and is refused with VersionConflictErr when
+// someone else got there first, rather than overwriting their change.
+
+var VersionConflictErr = fmt.Errorf("version conflict")
+
+// versioner is implemented by stores that keep list versions
+type versioner interface {
+	Version(key string) (int64, error)
+}
+
+// listVersion returns the version of a list, 0 when the store doesn't keep
+// them
+func (s *ToDoStore) listVersion(key string) (int64, error) {
+	store, ok := s.activeStore().(versioner)
+	if !ok {
+		return 0, nil
+	}
+	return store.Version(key)
+}
+
+// checkVersion refuses a change made against an older version of the list
+// or the item than the one there now
+func (s *ToDoStore) checkVersion(job DataStoreJob) error {
+	if job.ListVersion != 0 {
+		version, err := s.listVersion(job.key())
+		if err != nil {
+			return err
+		}
+		if version != job.ListVersion {
+			return fmt.Errorf("list is at version %d not %d %w", version, job.ListVersion, VersionConflictErr)
+		}
+	}
+	if job.Version != 0 {
+		userlist, err := s.activeStore().Fetch(job.key())
+		if err != nil {
+			return err
+		}
+		idx := findItem(userlist, job.KeyValue)
+		if idx == -1 {
+			return NotFoundErr
+		}
+		if version := userlist[idx].Version; version != job.Version {
+			return fmt.Errorf("%q is at version %d not %d %w", userlist[idx].Item, version, job.Version, VersionConflictErr)
+		}
+	}
+	return nil
+}
+
+// nextVersion returns item with a version after both its own and the one
+// of the item with the same id in the list now, for putting back an older
+// copy of it
+func (s *ToDoStore) nextVersion(key string, item ToDoItem) ToDoItem {
+	version := item.Version
+	if userlist, err := s.activeStore().Fetch(key); err == nil {
+		if idx := itemIndex(userlist, item.ItemId); idx != -1 {
+			version = max(version, userlist[idx].Version)
+		}
+	}
+	item.Version = version + 1
+	return item
+}
+
+// GetVersionedList returns a copy of a users list from the store in use
+// along with its version
+func (s *ToDoStore) GetVersionedList(uid string) (map[int]ToDoItem, int64) {
+	s.mutex.RLock()
+
+	defer func() {
+		s.mutex.RUnlock()
+	}()
+
+	userlist, err := s.activeStore().Fetch(uid)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("error %v fetching list", err))
+		return make(map[int]ToDoItem), 0
+	}
+	version, err := s.listVersion(uid)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("error %v fetching list version", err))
+	}
+	return userlist, version
+}
+
+// BasicUpdateToDoItemVersion is BasicUpdateToDoItem made against
+// listVersion of the list and version of the item, either can be 0 to
+// accept any
+func (s *ToDoStore) BasicUpdateToDoItemVersion(uid string, item string, replacewith string, listVersion int64, version int64) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	if err := s.checkVersion(DataStoreJob{Uid: uid, KeyValue: item, ListVersion: listVersion, Version: version}); err != nil {
+		return err
+	}
+	return s.recordChange(context.Background(), uid, "update", func() error {
+		_, err := s.changeItem(uid, item, func(todo *ToDoItem) {
+			todo.Item = replacewith
+		})
+		return err
+	})
+}
+
+// BasicDeleteToDoItemVersion is BasicDeleteToDoItem made against
+// listVersion of the list and version of the item, either can be 0 to
+// accept any
+func (s *ToDoStore) BasicDeleteToDoItemVersion(uid string, item string, listVersion int64, version int64) error {
+	s.mutex.Lock()
+
+	defer func() {
+		s.mutex.Unlock()
+	}()
+
+	if err := s.checkVersion(DataStoreJob{Uid: uid, KeyValue: item, ListVersion: listVersion, Version: version}); err != nil {
+		return err
+	}
+	return s.recordChange(context.Background(), uid, "delete", func() error {
+		_, err := s.deleteItem(uid, item)
+		return err
+	})
+}
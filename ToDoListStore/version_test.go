@@ -0,0 +1,59 @@
+package ToDoListStore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// a change made against the versions there now goes through, one made
+// against older ones is refused and changes nothing
+func TestVersionConflict(t *testing.T) {
+	s, err := New(WithStore(NewMemoryStore()), WithLogFile(os.DevNull))
+	if err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if _, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: AddData, KeyValue: "milk"}); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	versions := func() (int64, int64) {
+		t.Helper()
+		listVersion, err := s.listVersion("tester")
+		if err != nil {
+			t.Fatalf("Expected nil got %v", err)
+		}
+		return listVersion, fetchItem(t, s, "tester", "milk").Version
+	}
+	update := func(listVersion int64, version int64) error {
+		_, err := s.Do(ctx, DataStoreJob{Uid: "tester", JobType: UpdateData, KeyValue: "milk", AltValue: "milk", ListVersion: listVersion, Version: version})
+		return err
+	}
+
+	oldList, oldItem := versions()
+	if err := update(oldList, oldItem); err != nil {
+		t.Fatalf("Expected nil got %v", err)
+	}
+	listVersion, version := versions()
+	if listVersion <= oldList || version <= oldItem {
+		t.Errorf("Expected versions after %d %d got %d %d", oldList, oldItem, listVersion, version)
+	}
+
+	for _, stale := range [][2]int64{{oldList, 0}, {0, oldItem}, {oldList, version}, {listVersion, oldItem}} {
+		if err := update(stale[0], stale[1]); !errors.Is(err, VersionConflictErr) {
+			t.Errorf("%v: Expected VersionConflictErr got %v", stale, err)
+		}
+	}
+	if err := s.BasicDeleteToDoItemVersion("tester", "milk", oldList, 0); !errors.Is(err, VersionConflictErr) {
+		t.Errorf("Expected VersionConflictErr got %v", err)
+	}
+	if got, gotItem := versions(); got != listVersion || gotItem != version {
+		t.Errorf("Expected refused changes to leave %d %d got %d %d", listVersion, version, got, gotItem)
+	}
+
+	if err := s.BasicDeleteToDoItemVersion("tester", "milk", listVersion, version); err != nil {
+		t.Errorf("Expected nil got %v", err)
+	}
+}
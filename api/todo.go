@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -11,8 +12,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	_ "net/http/pprof"
 
@@ -21,11 +24,21 @@ import (
 )
 
 var portFlag = flag.String("port", "", "port to run on e.g. -port 8080")
+var storeFlag = flag.String("store", list.FileBackend, "where todo lists are kept: memory, file, todotxt or db e.g. -store db")
+var subtasksFlag = flag.String("subtasks", "cascade", "what completing, moving or deleting an item does to its sub-tasks: cascade or block")
+var timeoutFlag = flag.Duration("timeout", 30*time.Second, "how long a request can wait for the store e.g. -timeout 5s")
+var webhookFlag = flag.String("webhook", "", "also post reminders as JSON to this url e.g. -webhook http://localhost:9000/remind")
+var adminTokenFlag = flag.String("admintoken", "", "bearer token for the admin endpoints, which are off without it e.g. -admintoken s3cret")
+var remindLogFlag = flag.String("remindlog", "", "also append reminders as JSON to this file e.g. -remindlog reminders.log")
+
+// Reminders keeps the latest reminders for each user for /todo/reminders
+var Reminders = list.NewMemoryNotifier(100)
 
 type RequestJob struct {
 	Writer  http.ResponseWriter
 	Request *http.Request
 	uid     string
+	list    string
 	done    chan struct{}
 }
 
@@ -37,7 +50,11 @@ var Queue = make(chan RequestJob)
 
 type todoPageData struct {
 	PageTitle string
-	Items     []list.ToDoItem
+	Uid       string
+	List      string
+	Tag       string
+	Sort      string
+	Items     []list.ToDoNode
 }
 
 func TracingMiddleware(next http.Handler) http.Handler {
@@ -48,7 +65,11 @@ func TracingMiddleware(next http.Handler) http.Handler {
 		if requestId == "" {
 			requestId = uuid.NewString()
 		}
-		ctx := context.WithValue(r.Context(), key, requestId)
+		ctx := list.WithRequestId(r.Context(), requestId)
+		// who is making the change when it isn't the owner of the list
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			ctx = list.WithActor(ctx, actor)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -66,20 +87,8 @@ func postRequest(job RequestJob) {
 		http.Error(job.Writer, err.Error(), http.StatusBadRequest)
 		return
 	}
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.AddData, KeyValue: pb["item"], AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
-	list.DataJobQueue <- data
-	returnVal, ok := <-data.ReturnChannel
-	if ok {
-		if returnVal.Err != nil {
-			message := fmt.Sprintf("error adding data data %v", err)
-			LogThis(job.Request.Context(), list.ErrorLog, message)
-			if errors.Is(returnVal.Err, list.AlreadyExistsErr) {
-				job.Writer.Write([]byte("Already Exists"))
-			} else {
-				job.Writer.WriteHeader(http.StatusInternalServerError)
-			}
-		}
-	}
+	// the item is added with its repeat rule, if it has one, in one change
+	runDataJob(job, list.DataStoreJob{JobType: list.AddData, KeyValue: pb["item"], AltValue: pb["parent"], Repeat: pb["repeat"]})
 }
 
 func putRequest(job RequestJob) {
@@ -93,23 +102,18 @@ func putRequest(job RequestJob) {
 		return
 	}
 
-	if pb["item"] == "" || pb["replacewith"] == "" {
+	if itemKey(pb) == "" || pb["replacewith"] == "" {
 		job.Writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.UpdateData, KeyValue: pb["item"], AltValue: pb["replacewith"], ReturnChannel: make(chan list.ReturnChannelData)}
-	list.DataJobQueue <- data
-	returnVal, ok := <-data.ReturnChannel
+	listVersion, version, status := expectedVersions(job.Request, pb)
+	if status != http.StatusOK {
+		job.Writer.WriteHeader(status)
+		return
+	}
+	returnVal, ok := runDataJob(job, list.DataStoreJob{JobType: list.UpdateData, KeyValue: itemKey(pb), AltValue: pb["replacewith"], ListVersion: listVersion, Version: version})
 	if ok {
-		if returnVal.Err != nil {
-			message := fmt.Sprintf("error updating data data %v", returnVal.Err)
-			LogThis(job.Request.Context(), list.ErrorLog, message)
-			if errors.Is(returnVal.Err, list.NotFoundErr) {
-				job.Writer.WriteHeader(http.StatusNotFound)
-			} else {
-				job.Writer.WriteHeader(http.StatusInternalServerError)
-			}
-		}
+		job.Writer.Header().Set("ETag", etag(returnVal.Version))
 	}
 }
 
@@ -123,20 +127,70 @@ func deleteRequest(job RequestJob) {
 		job.Writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.DeleteData, KeyValue: db["item"], AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
-	list.DataJobQueue <- data
-	returnVal, ok := <-data.ReturnChannel
+	listVersion, version, status := expectedVersions(job.Request, db)
+	if status != http.StatusOK {
+		job.Writer.WriteHeader(status)
+		return
+	}
+	returnVal, ok := runDataJob(job, list.DataStoreJob{JobType: list.DeleteData, KeyValue: itemKey(db), ListVersion: listVersion, Version: version})
 	if ok {
-		if returnVal.Err != nil {
-			message := fmt.Sprintf("error deleting data %v", returnVal.Err)
-			LogThis(job.Request.Context(), list.ErrorLog, message)
-			if errors.Is(returnVal.Err, list.NotFoundErr) {
-				job.Writer.WriteHeader(http.StatusNotFound)
-			} else {
-				job.Writer.WriteHeader(http.StatusInternalServerError)
-			}
-		}
+		job.Writer.Header().Set("ETag", etag(returnVal.Version))
+	}
+}
+
+// patchRequest marks an item as complete, or as not done when the body
+// contains "done": "false". a body with "tag" or "untag" adds or removes
+// that tag instead, one with "parent" moves the item under that item, or to
+// the top level when it is empty, one with "repeat" sets how often it
+// recurs, or stops it when it is empty or "never", and ones with "due" or
+// "remind" set when it is due and how long before to remind, or clear them
+// when they are empty or "none". one with "position" moves the item in the
+// manual order, to a number, first, last, "before 2" or "after milk", and
+// one with "priority" sets its priority, A to Z, or clears it when it is
+// empty or "none". a body with more than one of these is refused.
+func patchRequest(job RequestJob) {
+	defer close(job.done)
+	var pb = make(map[string]string)
+	err := json.NewDecoder(job.Request.Body).Decode(&pb)
+	if err != nil {
+		message := fmt.Sprintf("error decoding data data %v", err)
+		LogThis(job.Request.Context(), list.ErrorLog, message)
+		http.Error(job.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if itemKey(pb) == "" {
+		job.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if tooManyChanges(job.Writer, pb) {
+		return
+	}
+	jobType := list.JobType(list.CompleteData)
+	// the tag, the new parent, the repeat rule, the due date, the reminders,
+	// the position or the priority
+	tag := ""
+	switch {
+	case hasKey(pb, "tag"):
+		jobType, tag = list.TagData, pb["tag"]
+	case hasKey(pb, "untag"):
+		jobType, tag = list.UntagData, pb["untag"]
+	case hasKey(pb, "parent"):
+		jobType, tag = list.MoveData, pb["parent"]
+	case hasKey(pb, "repeat"):
+		jobType, tag = list.RepeatData, pb["repeat"]
+	case hasKey(pb, "due"):
+		jobType, tag = list.DueData, pb["due"]
+	case hasKey(pb, "remind"):
+		jobType, tag = list.RemindData, pb["remind"]
+	case hasKey(pb, "position"):
+		jobType, tag = list.ReorderData, pb["position"]
+	case hasKey(pb, "priority"):
+		jobType, tag = list.PriorityData, pb["priority"]
+	case pb["done"] == "false":
+		jobType = list.ReopenData
 	}
+	runJob(job, jobType, itemKey(pb), tag)
 }
 
 func serveTemplate(job RequestJob) {
@@ -145,23 +199,35 @@ func serveTemplate(job RequestJob) {
 
 	pageData := todoPageData{
 		PageTitle: "TO DO LIST FOR " + job.uid,
+		Uid:       job.uid,
+		List:      job.list,
+		Tag:       job.Request.FormValue("tag"),
+		Sort:      job.Request.FormValue("sort"),
 	}
 
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.FetchData, KeyValue: "", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
-	list.DataJobQueue <- data
-	returnVal, ok := <-data.ReturnChannel
-	if ok {
-		if returnVal.Err != nil {
-			message := fmt.Sprintf("error fetching data %v", returnVal.Err)
-			LogThis(job.Request.Context(), list.ErrorLog, message)
-			job.Writer.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	jobType := list.JobType(list.FetchData)
+	if pageData.Tag != "" {
+		jobType = list.FilterData
+	}
+	returnVal, ok := runJob(job, jobType, pageData.Tag, "")
+	if !ok {
+		return
 	}
 
-	pageData.Items = list.SortedArray(returnVal.List)
+	items := list.SortedArray(returnVal.List)
+	if err := list.SortItems(items, pageData.Sort); err != nil {
+		http.Error(job.Writer, err.Error(), errorStatus(err))
+		return
+	}
+	pageData.Items = list.Tree(items)
+	job.Writer.Header().Set("ETag", etag(returnVal.Version))
+	if wantsJSON(job.Request) {
+		job.Writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(job.Writer).Encode(pageData.Items)
+		return
+	}
 
-	tmpl, err := template.New("layout.html").ParseFiles(lp)
+	tmpl, err := template.New("layout.html").Funcs(template.FuncMap{"uid": func() string { return job.uid }}).ParseFiles(lp)
 	if err != nil {
 		message := fmt.Sprintf("error parsing list template %v", err)
 		LogThis(job.Request.Context(), list.ErrorLog, message)
@@ -174,36 +240,466 @@ func serveTemplate(job RequestJob) {
 	}
 }
 
+// ProcessHttpQueue hands each request to its own goroutine. the data job
+// queue keeps each users requests in order so one slow user doesn't hold
+// up everyone else.
 func ProcessHttpQueue() {
 	for v := range Queue {
-		message := fmt.Sprintf("Processing %s Request for %s", v.Request.Method, v.Request.RequestURI)
-		LogThis(v.Request.Context(), list.InfoLog, message)
-		switch strings.ToUpper(v.Request.Method) {
-		case "POST":
-			postRequest(v)
-		case "PUT":
-			putRequest(v)
-		case "DELETE":
-			deleteRequest(v)
-		case "GET":
-			serveTemplate(v)
-		default:
-			v.Writer.WriteHeader(http.StatusMethodNotAllowed)
-		}
+		go serveRequest(v)
+	}
+}
+
+func serveRequest(v RequestJob) {
+	message := fmt.Sprintf("Processing %s Request for %s", v.Request.Method, v.Request.RequestURI)
+	LogThis(v.Request.Context(), list.InfoLog, message)
+	switch strings.ToUpper(v.Request.Method) {
+	case "POST":
+		postRequest(v)
+	case "PUT":
+		putRequest(v)
+	case "DELETE":
+		deleteRequest(v)
+	case "PATCH":
+		patchRequest(v)
+	case "GET":
+		serveTemplate(v)
+	default:
+		v.Writer.WriteHeader(http.StatusMethodNotAllowed)
+		close(v.done)
 	}
 }
 
 var ProcessRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-	//extract uid from url
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	ctx := job.Request.Context()
+	select {
+	case Queue <- job:
+	case <-ctx.Done():
+		w.WriteHeader(contextStatus(ctx.Err()))
+		return
+	}
+	<-job.done
+})
+
+// ProcessListRequest manages a users lists. GET returns the names of their
+// lists, POST with {"name": "..."} creates one, and on /todo/lists/{list}
+// PATCH with {"name": "..."} renames it and DELETE deletes it.
+var ProcessListRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	data := list.DataStoreJob{JobType: list.FetchListsData}
+	var body = make(map[string]string)
+	if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		data.JobType, data.List = list.CreateListData, body["name"]
+	case http.MethodPatch:
+		data.JobType, data.AltValue = list.RenameListData, body["name"]
+	case http.MethodDelete:
+		data.JobType = list.DeleteListData
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	returnVal, ok := runDataJob(job, data)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(returnVal.Lists)
+})
+
+// ProcessSearchRequest returns the items on a list matching ?q=, best
+// match first
+var ProcessSearchRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	returnVal, ok := runJob(job, list.SearchData, r.FormValue("q"), "")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(returnVal.Found)
+})
+
+// ProcessReminderRequest returns the latest reminders that have come due
+// for a user, oldest first
+var ProcessReminderRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Reminders.Events(job.uid))
+})
+
+// ProcessUndoRequest undoes, on /todo/undo, or redoes, on /todo/redo, the
+// latest change to a users lists. it returns what was changed and the list
+// as it is now.
+var ProcessUndoRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	jobType := list.JobType(list.UndoData)
+	if strings.HasSuffix(r.URL.Path, "/redo") {
+		jobType = list.RedoData
+	}
+	returnVal, ok := runJob(job, jobType, "", "")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Op    string          `json:"op"`
+		Items []list.ToDoItem `json:"items"`
+	}{returnVal.Op, list.SortedArray(returnVal.List)})
+})
+
+// batchResponse is what a batch did, Error is why nothing changed
+type batchResponse struct {
+	Results []list.BatchResult `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// ProcessBatchRequest makes the changes in a JSON array of operations all
+// or nothing, returning what happened to each one. operations without a
+// uid or list are made to ?uid= and the list in the path.
+var ProcessBatchRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	ops := make([]list.BatchOp, 0)
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	returnVal, err := doJob(job, list.DataStoreJob{JobType: list.BatchData, Batch: ops})
+	response := batchResponse{Results: returnVal.Results}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(errorStatus(err))
+	}
+	json.NewEncoder(w).Encode(response)
+})
+
+// the content type of each export format
+var exportTypes = map[string]string{
+	list.JSONFormat:     "application/json",
+	list.CSVFormat:      "text/csv; charset=utf-8",
+	list.MarkdownFormat: "text/markdown; charset=utf-8",
+	list.ToDoTxtFormat:  "text/plain; charset=utf-8",
+	list.ICalFormat:     "text/calendar; charset=utf-8",
+}
+
+// the file extension of each export format
+var exportExtensions = map[string]string{
+	list.JSONFormat:     ".json",
+	list.CSVFormat:      ".csv",
+	list.MarkdownFormat: ".md",
+	list.ToDoTxtFormat:  ".txt",
+	list.ICalFormat:     ".ics",
+}
+
+// ProcessExportRequest returns every list of ?uid=, or the list in the
+// path, in ?format= json, the default, csv, markdown, todotxt or ical
+var ProcessExportRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	format := list.JSONFormat
+	if f := r.URL.Query().Get("format"); f != "" {
+		var err error
+		if format, err = list.ParseFormat(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	exportLists(w, r, format, "attachment")
+})
+
+// ProcessCalendarRequest returns every list of ?uid=, or the list in the
+// path, as an iCalendar feed a calendar app can subscribe to
+var ProcessCalendarRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	exportLists(w, r, list.ICalFormat, "inline")
+})
+
+// exportLists writes the lists asked for in format, as an attachment to
+// save or inline
+func exportLists(w http.ResponseWriter, r *http.Request, format string, disposition string) {
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	if job.list == "" {
+		job.list = list.AllLists
+	}
+	returnVal, ok := runJob(job, list.ExportData, "", "")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", exportTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, "todo"+exportExtensions[format]))
+	if err := list.WriteItems(w, format, returnVal.Items); err != nil {
+		LogThis(job.Request.Context(), list.ErrorLog, fmt.Sprintf("error writing export %v", err))
+	}
+}
+
+// ProcessImportRequest adds the items in the body, in ?format= json, the
+// default, csv, markdown, todotxt or ical, to the lists they name or the list in
+// the path, skipping duplicates. it returns what was added and skipped,
+// and with ?dryrun=true only what would be.
+var ProcessImportRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	job, cancel := newRequestJob(w, r)
+	defer cancel()
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = list.JSONFormat
+	}
+	items, err := list.ReadItems(r.Body, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryrun"))
+	returnVal, ok := runDataJob(job, list.DataStoreJob{JobType: list.ImportData, Items: items, DryRun: dryRun})
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(returnVal.Imported)
+})
+
+// ProcessAuditRequest returns the events in the audit log, oldest first. it
+// needs the admin token and takes ?uid=, ?actor=, ?op=, ?from=, ?to= and
+// ?limit= to narrow them down.
+var ProcessAuditRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(*adminTokenFlag)) != 1 {
+		http.Error(w, "not allowed", http.StatusForbidden)
+		return
+	}
+	filter := list.AuditFilter{Uid: r.FormValue("uid"), Actor: r.FormValue("actor"), Op: r.FormValue("op")}
+	for _, v := range []struct {
+		value string
+		to    *time.Time
+	}{{r.FormValue("from"), &filter.From}, {r.FormValue("to"), &filter.To}} {
+		if v.value == "" {
+			continue
+		}
+		var err error
+		if *v.to, err = list.ParseDue(v.value, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", limit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := list.QueryAudit(filter)
+	if err != nil {
+		LogThis(r.Context(), list.ErrorLog, fmt.Sprintf("error reading audit log %v", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+})
+
+// newRequestJob returns the job for a request, for the user in ?uid= and the
+// list in the path, with a context that ends after -timeout. cancel it when
+// the request is done.
+func newRequestJob(w http.ResponseWriter, r *http.Request) (RequestJob, context.CancelFunc) {
 	uid := "Anonymous User"
 	err := r.ParseForm()
 	if err == nil {
 		uid = r.FormValue("uid")
 	}
-	data := RequestJob{w, r, uid, make(chan struct{})}
-	Queue <- data
-	<-data.done
-})
+	ctx, cancel := context.WithTimeout(r.Context(), *timeoutFlag)
+	return RequestJob{Writer: w, Request: r.WithContext(ctx), uid: uid, list: r.PathValue("list"), done: make(chan struct{})}, cancel
+}
+
+// runJob runs a data job of type jt on key and alt for the user and list
+// of a request. when it fails the error is logged and written back with its
+// status, and ok is false.
+func runJob(job RequestJob, jt list.JobType, key string, alt string) (list.ReturnChannelData, bool) {
+	return runDataJob(job, list.DataStoreJob{JobType: jt, KeyValue: key, AltValue: alt})
+}
+
+// runDataJob is runJob for a job needing more than a key and alt, like a
+// version to check or items to import
+func runDataJob(job RequestJob, data list.DataStoreJob) (list.ReturnChannelData, bool) {
+	returnVal, err := doJob(job, data)
+	if err == nil {
+		return returnVal, true
+	}
+	if data.JobType == list.AddData && errors.Is(err, list.AlreadyExistsErr) {
+		// adding an item that is already there isn't an error
+		job.Writer.Write([]byte("Already Exists"))
+		return returnVal, false
+	}
+	http.Error(job.Writer, err.Error(), errorStatus(err))
+	return returnVal, false
+}
+
+// doJob runs a data job for the user and list of a request, on the list in
+// data if it has one, logging the error when it fails
+func doJob(job RequestJob, data list.DataStoreJob) (list.ReturnChannelData, error) {
+	ctx := job.Request.Context()
+	data.Context, data.Uid = ctx, job.uid
+	if data.List == "" {
+		data.List = job.list
+	}
+	data.ReturnChannel = make(chan list.ReturnChannelData, 1)
+	returnVal, err := list.Do(ctx, data)
+	if err != nil {
+		LogThis(ctx, list.ErrorLog, fmt.Sprintf("error with %s %s %v", job.Request.Method, job.Request.URL.Path, err))
+	}
+	return returnVal, err
+}
+
+// errorStatus is the http status for an error returned by a data job
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, list.NotFoundErr):
+		return http.StatusNotFound
+	case errors.Is(err, list.AlreadyExistsErr), errors.Is(err, list.ChildrenErr), errors.Is(err, list.NothingToUndoErr), errors.Is(err, list.NothingToRedoErr):
+		return http.StatusConflict
+	case errors.Is(err, list.InvalidTagErr), errors.Is(err, list.InvalidListNameErr), errors.Is(err, list.DefaultListErr), errors.Is(err, list.CycleErr), errors.Is(err, list.InvalidRepeatErr), errors.Is(err, list.InvalidDueErr), errors.Is(err, list.InvalidReminderErr), errors.Is(err, list.EmptySearchErr), errors.Is(err, list.UnknownOperationErr), errors.Is(err, list.EmptyBatchErr), errors.Is(err, list.InvalidPositionErr), errors.Is(err, list.InvalidPriorityErr), errors.Is(err, list.InvalidSortErr), errors.Is(err, list.UnknownFormatErr), errors.Is(err, list.InvalidImportErr):
+		return http.StatusBadRequest
+	case errors.Is(err, list.VersionConflictErr):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, list.TimeoutErr):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, list.CanceledErr):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// contextStatus is the http status for a request whose context ended
+func contextStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusServiceUnavailable
+}
+
+// wantsJSON reports whether a GET asked for the list as JSON, with
+// ?format=json or an Accept header, rather than as a page
+func wantsJSON(r *http.Request) bool {
+	return r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// patchKeys are the changes a PATCH can make to an item
+var patchKeys = []string{"tag", "untag", "parent", "repeat", "due", "remind", "position", "priority", "done"}
+
+// tooManyChanges writes a bad request, and reports true, when a PATCH body
+// asks for more than one change, which would otherwise be ignored
+func tooManyChanges(w http.ResponseWriter, body map[string]string) bool {
+	changes := make([]string, 0, 1)
+	for _, v := range patchKeys {
+		if hasKey(body, v) {
+			changes = append(changes, v)
+		}
+	}
+	if len(changes) < 2 {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("only one of %s can be changed at a time", strings.Join(changes, ", ")), http.StatusBadRequest)
+	return true
+}
+
+// hasKey reports whether a request body contains key, even if it is empty
+func hasKey(body map[string]string, key string) bool {
+	_, found := body[key]
+	return found
+}
+
+// itemKey returns the item an update, delete or status change applies to.
+// the id (or list number) is preferred and the item text is the fallback
+func itemKey(body map[string]string) string {
+	if id := body["id"]; id != "" {
+		return id
+	}
+	return body["item"]
+}
+
+// etag is the ETag of a list at version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// expectedVersions returns the version of the list a change expects, from
+// If-Match, and of the item, from "version" in the body, 0 for any. the
+// status is StatusOK unless If-Match can never match or the version is
+// invalid.
+func expectedVersions(r *http.Request, body map[string]string) (int64, int64, int) {
+	listVersion := int64(0)
+	if match := strings.TrimSpace(r.Header.Get("If-Match")); match != "" && match != "*" {
+		unquoted, quoted := strings.CutPrefix(match, `"`)
+		unquoted, closed := strings.CutSuffix(unquoted, `"`)
+		var err error
+		if listVersion, err = strconv.ParseInt(unquoted, 10, 64); !quoted || !closed || err != nil || listVersion < 1 {
+			return 0, 0, http.StatusPreconditionFailed
+		}
+	}
+	version := int64(0)
+	if body["version"] != "" {
+		var err error
+		if version, err = strconv.ParseInt(body["version"], 10, 64); err != nil || version < 1 {
+			return 0, 0, http.StatusBadRequest
+		}
+	}
+	return listVersion, version, http.StatusOK
+}
 
 func LogThis(ctx context.Context, level list.LogType, message string) {
 	data := list.LoggerJob{Context: ctx, LogMessage: message, LogType: level}
@@ -217,9 +713,32 @@ func main() {
 	filename := fmt.Sprintf("todo%s.txt", port)
 
 	ctx := context.Background()
+	store, err := list.NewStore(*storeFlag, filename)
+	if err != nil {
+		fmt.Printf("error opening store: %s\n", err)
+		return
+	}
+	list.UseStore(store)
+
+	policy, err := list.ParseChildPolicy(*subtasksFlag)
+	if err != nil {
+		fmt.Printf("error parsing command line: %s\n", err)
+		return
+	}
+	list.SetChildPolicy(policy)
+
+	list.AddNotifier(Reminders)
+	if *webhookFlag != "" {
+		list.AddNotifier(list.NewWebhookNotifier(*webhookFlag))
+	}
+	if *remindLogFlag != "" {
+		list.AddNotifier(list.NewFileNotifier(*remindLogFlag))
+	}
+
 	go ProcessHttpQueue()
 	go list.ProcessLoggerJobs()
 	go list.ProcessDataJobs()
+	go list.ProcessReminders()
 
 	data := list.DataStoreJob{Context: ctx, Uid: "", JobType: list.LoadData, KeyValue: filename, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 	list.DataJobQueue <- data
@@ -256,7 +775,28 @@ func main() {
 	fs := http.FileServer(http.Dir("./static"))
 	mux.Handle("/debug/", http.DefaultServeMux)
 	mux.Handle("/todo", TracingMiddleware(ProcessRequest))
+	mux.Handle("/todo/search", TracingMiddleware(ProcessSearchRequest))
+	mux.Handle("/todo/reminders", TracingMiddleware(ProcessReminderRequest))
+	mux.Handle("/todo/undo", TracingMiddleware(ProcessUndoRequest))
+	mux.Handle("/todo/redo", TracingMiddleware(ProcessUndoRequest))
+	mux.Handle("/todo/batch", TracingMiddleware(ProcessBatchRequest))
+	mux.Handle("/todo/export", TracingMiddleware(ProcessExportRequest))
+	mux.Handle("/todo/import", TracingMiddleware(ProcessImportRequest))
+	mux.Handle("/todo/calendar.ics", TracingMiddleware(ProcessCalendarRequest))
+	mux.Handle("/todo/lists", TracingMiddleware(ProcessListRequest))
+	mux.Handle("/todo/lists/{list}", TracingMiddleware(ProcessListRequest))
+	mux.Handle("/todo/lists/{list}/items", TracingMiddleware(ProcessRequest))
+	mux.Handle("/todo/lists/{list}/search", TracingMiddleware(ProcessSearchRequest))
+	mux.Handle("/todo/lists/{list}/undo", TracingMiddleware(ProcessUndoRequest))
+	mux.Handle("/todo/lists/{list}/redo", TracingMiddleware(ProcessUndoRequest))
+	mux.Handle("/todo/lists/{list}/batch", TracingMiddleware(ProcessBatchRequest))
+	mux.Handle("/todo/lists/{list}/export", TracingMiddleware(ProcessExportRequest))
+	mux.Handle("/todo/lists/{list}/import", TracingMiddleware(ProcessImportRequest))
+	mux.Handle("/todo/lists/{list}/calendar.ics", TracingMiddleware(ProcessCalendarRequest))
 	mux.Handle("/todo/", http.StripPrefix("/todo/", fs))
+	if *adminTokenFlag != "" {
+		mux.Handle("/admin/audit", TracingMiddleware(ProcessAuditRequest))
+	}
 
 	fmt.Printf("\nListening on port %s\n", port)
 	if err := http.ListenAndServe(port, mux); err != nil {
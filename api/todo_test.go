@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	list "github.com/simonedz197/ToDoListStore"
+)
+
+func TestMain(m *testing.M) {
+	list.Default.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	list.UseStore(list.NewMemoryStore())
+	go ProcessHttpQueue()
+	go list.ProcessLoggerJobs()
+	go list.ProcessDataJobs()
+	os.Exit(m.Run())
+}
+
+// serve sends a request for the tester to /todo and returns the response
+func serve(method string, body string, ifMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/todo?uid=tester&format=json", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	ProcessRequest.ServeHTTP(w, r)
+	return w
+}
+
+// a change with an If-Match of the list as it is now goes through and one
+// with an older ETag is refused
+func TestIfMatch(t *testing.T) {
+	if w := serve(http.MethodPost, `{"item":"milk"}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 got %d %s", w.Code, w.Body)
+	}
+	stale := serve(http.MethodGet, "", "").Header().Get("ETag")
+	w := serve(http.MethodPut, `{"item":"milk","replacewith":"oat milk"}`, stale)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 got %d %s", w.Code, w.Body)
+	}
+	current := w.Header().Get("ETag")
+	if current == "" || current == stale {
+		t.Fatalf("Expected a new ETag after %s got %q", stale, current)
+	}
+
+	for _, ifMatch := range []string{stale, `"0"`, "1"} {
+		if w := serve(http.MethodPut, `{"item":"oat milk","replacewith":"soya milk"}`, ifMatch); w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: Expected 412 got %d %s", ifMatch, w.Code, w.Body)
+		}
+	}
+	if w := serve(http.MethodDelete, `{"item":"oat milk"}`, stale); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 got %d %s", w.Code, w.Body)
+	}
+	if got := serve(http.MethodGet, "", "").Header().Get("ETag"); got != current {
+		t.Errorf("Expected refused changes to leave %s got %s", current, got)
+	}
+
+	if w := serve(http.MethodDelete, `{"item":"oat milk"}`, current); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 got %d %s", w.Code, w.Body)
+	}
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	list "github.com/simonedz197/ToDoListStore"
@@ -26,13 +31,26 @@ type RequestJob struct {
 
 type RequetHeaderKey string
 
+var storeFlag = flag.String("store", list.FileBackend, "where todo lists are kept: memory, file, todotxt or db e.g. -store db")
+var subtasksFlag = flag.String("subtasks", "cascade", "what completing, moving or deleting an item does to its sub-tasks: cascade or block")
+var webhookFlag = flag.String("webhook", "", "also post reminders as JSON to this url e.g. -webhook http://localhost:9000/remind")
+var adminTokenFlag = flag.String("admintoken", "", "bearer token for the admin endpoints, which are off without it e.g. -admintoken s3cret")
+var remindLogFlag = flag.String("remindlog", "", "also append reminders as JSON to this file e.g. -remindlog reminders.log")
+
+// Reminders keeps the latest reminders for each user for /todo/reminders
+var Reminders = list.NewMemoryNotifier(100)
+
 const IdRequestHeader = "X-Request-ID"
 
 var Queue = make(chan RequestJob)
 
 type todoPageData struct {
 	PageTitle string
-	Items     []list.ToDoItem
+	Uid       string
+	List      string
+	Tag       string
+	Sort      string
+	Items     []list.ToDoNode
 }
 
 func TracingMiddleware(next http.Handler) http.Handler {
@@ -41,7 +59,10 @@ func TracingMiddleware(next http.Handler) http.Handler {
 		if requestId == "" {
 			requestId = uuid.NewString()
 		}
-		ctx := context.WithValue(r.Context(), IdRequestHeader, requestId)
+		ctx := list.WithRequestId(r.Context(), requestId)
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			ctx = list.WithActor(ctx, actor)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -80,11 +101,11 @@ func putRequest(job RequestJob) {
 		return
 	}
 
-	if pb["item"] == "" || pb["replacewith"] == "" {
+	if itemKey(pb) == "" || pb["replacewith"] == "" {
 		job.Writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.UpdateData, KeyValue: pb["item"], AltValue: pb["replacewith"], ReturnChannel: make(chan list.ReturnChannelData)}
+	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.UpdateData, KeyValue: itemKey(pb), AltValue: pb["replacewith"], ReturnChannel: make(chan list.ReturnChannelData)}
 	list.DataJobQueue <- data
 	returnVal, ok := <-data.ReturnChannel
 	if ok {
@@ -108,7 +129,7 @@ func deleteRequest(job RequestJob) {
 		job.Writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.UpdateData, KeyValue: db["item"], AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+	data := list.DataStoreJob{Context: job.Request.Context(), Uid: job.uid, JobType: list.DeleteData, KeyValue: itemKey(db), ReturnChannel: make(chan list.ReturnChannelData)}
 	list.DataJobQueue <- data
 	returnVal, ok := <-data.ReturnChannel
 	if ok {
@@ -143,9 +164,9 @@ func serveTemplate(job RequestJob) {
 		}
 	}
 
-	pageData.Items = list.SortedArray(returnVal.List)
+	pageData.Items = list.Tree(list.SortedArray(returnVal.List))
 
-	tmpl, err := template.New("layout.html").ParseFiles(lp)
+	tmpl, err := template.New("layout.html").Funcs(template.FuncMap{"uid": func() string { return job.uid }}).ParseFiles(lp)
 	if err != nil {
 		list.Logger.ErrorContext(job.Request.Context(), "error parsing list template")
 		return
@@ -189,6 +210,75 @@ var ProcessRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Reques
 	<-data.done
 })
 
+// wantsJSON reports whether a GET asked for the list as JSON, with
+// ?format=json or an Accept header, rather than as a page
+func wantsJSON(r *http.Request) bool {
+	return r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// patchKeys are the changes a PATCH can make to an item
+var patchKeys = []string{"tag", "untag", "parent", "repeat", "due", "remind", "position", "priority", "done"}
+
+// tooManyChanges writes a bad request, and reports true, when a PATCH body
+// asks for more than one change, which would otherwise be ignored
+func tooManyChanges(w http.ResponseWriter, body map[string]string) bool {
+	changes := make([]string, 0, 1)
+	for _, v := range patchKeys {
+		if hasKey(body, v) {
+			changes = append(changes, v)
+		}
+	}
+	if len(changes) < 2 {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("only one of %s can be changed at a time", strings.Join(changes, ", ")), http.StatusBadRequest)
+	return true
+}
+
+// hasKey reports whether a request body contains key, even if it is empty
+func hasKey(body map[string]string, key string) bool {
+	_, found := body[key]
+	return found
+}
+
+// itemKey returns the item an update, delete or status change applies to.
+// the id (or list number) is preferred and the item text is the fallback
+func itemKey(body map[string]string) string {
+	if id := body["id"]; id != "" {
+		return id
+	}
+	return body["item"]
+}
+
+// etag is the ETag of a list at version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// expectedVersions returns the version of the list a change expects, from
+// If-Match, and of the item, from "version" in the body, 0 for any. the
+// status is StatusOK unless If-Match can never match or the version is
+// invalid.
+func expectedVersions(r *http.Request, body map[string]string) (int64, int64, int) {
+	listVersion := int64(0)
+	if match := strings.TrimSpace(r.Header.Get("If-Match")); match != "" && match != "*" {
+		unquoted, quoted := strings.CutPrefix(match, `"`)
+		unquoted, closed := strings.CutSuffix(unquoted, `"`)
+		var err error
+		if listVersion, err = strconv.ParseInt(unquoted, 10, 64); !quoted || !closed || err != nil || listVersion < 1 {
+			return 0, 0, http.StatusPreconditionFailed
+		}
+	}
+	version := int64(0)
+	if body["version"] != "" {
+		var err error
+		if version, err = strconv.ParseInt(body["version"], 10, 64); err != nil || version < 1 {
+			return 0, 0, http.StatusBadRequest
+		}
+	}
+	return listVersion, version, http.StatusOK
+}
+
 var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	//extract uid from url
 	uid := "Anonymous User"
@@ -198,6 +288,20 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 	}
 
 	list.Logger.InfoContext(r.Context(), "processing http request")
+	// the Basic functions work on a named list when given its key
+	listName := r.PathValue("list")
+	if listName != "" {
+		lists, err := list.BasicLists(uid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !slices.Contains(lists, listName) {
+			http.Error(w, fmt.Sprintf("list %q %v", listName, list.NotFoundErr), http.StatusNotFound)
+			return
+		}
+	}
+	key := list.ListKey(uid, listName)
 	// create a stuct and call the appropriate function
 
 	switch r.Method {
@@ -207,9 +311,13 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 		if err != nil {
 			list.Logger.ErrorContext(r.Context(), fmt.Sprintf("%v", err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		err = list.BasicAddToDoItem(uid, pb["item"])
-		if err != nil {
+		// the item is added with its repeat rule, if it has one, in one change
+		err = list.BasicAddRecurringToDoItem(key, pb["parent"], pb["item"], pb["repeat"])
+		if errors.Is(err, list.InvalidRepeatErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -219,9 +327,17 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 		if err != nil {
 			list.Logger.ErrorContext(r.Context(), fmt.Sprintf("%v", err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		err = list.BasicUpdateToDoItem(uid, pb["item"], pb["replacewith"])
-		if err != nil {
+		listVersion, version, status := expectedVersions(r, pb)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		err = list.BasicUpdateToDoItemVersion(key, itemKey(pb), pb["replacewith"], listVersion, version)
+		if errors.Is(err, list.VersionConflictErr) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -231,9 +347,58 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 		if err != nil {
 			list.Logger.ErrorContext(r.Context(), fmt.Sprintf("%v", err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		listVersion, version, status := expectedVersions(r, pb)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		err = list.BasicDeleteToDoItemVersion(key, itemKey(pb), listVersion, version)
+		if errors.Is(err, list.VersionConflictErr) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-		err = list.BasicDeleteToDoItem(uid, pb["item"])
+		return
+	case http.MethodPatch:
+		var pb = make(map[string]string)
+		err := json.NewDecoder(r.Body).Decode(&pb)
 		if err != nil {
+			list.Logger.ErrorContext(r.Context(), fmt.Sprintf("%v", err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tooManyChanges(w, pb) {
+			return
+		}
+		switch {
+		case hasKey(pb, "tag"):
+			err = list.BasicTagToDoItem(key, itemKey(pb), pb["tag"])
+		case hasKey(pb, "untag"):
+			err = list.BasicUntagToDoItem(key, itemKey(pb), pb["untag"])
+		case hasKey(pb, "parent"):
+			err = list.BasicMoveToDoItem(key, itemKey(pb), pb["parent"])
+		case hasKey(pb, "repeat"):
+			err = list.BasicRepeatToDoItem(key, itemKey(pb), pb["repeat"])
+		case hasKey(pb, "due"):
+			err = list.BasicDueToDoItem(key, itemKey(pb), pb["due"])
+		case hasKey(pb, "remind"):
+			err = list.BasicRemindToDoItem(key, itemKey(pb), pb["remind"])
+		case hasKey(pb, "position"):
+			err = list.BasicReorderToDoItem(key, itemKey(pb), pb["position"])
+		case hasKey(pb, "priority"):
+			err = list.BasicPriorityToDoItem(key, itemKey(pb), pb["priority"])
+		case pb["done"] == "false":
+			err = list.BasicReopenToDoItem(key, itemKey(pb))
+		default:
+			err = list.BasicCompleteToDoItem(key, itemKey(pb))
+		}
+		if errors.Is(err, list.InvalidTagErr) || errors.Is(err, list.CycleErr) || errors.Is(err, list.InvalidRepeatErr) || errors.Is(err, list.InvalidDueErr) || errors.Is(err, list.InvalidReminderErr) || errors.Is(err, list.InvalidPositionErr) || errors.Is(err, list.InvalidPriorityErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if errors.Is(err, list.ChildrenErr) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -243,13 +408,35 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 
 		pageData := todoPageData{
 			PageTitle: "TO DO LIST FOR " + uid,
+			Uid:       uid,
+			List:      listName,
+			Tag:       r.FormValue("tag"),
+			Sort:      r.FormValue("sort"),
 		}
 		list.Logger.InfoContext(r.Context(), "Getting user data")
-		itemList := list.GetUserList(uid)
+		itemList, version := list.GetVersionedList(key)
+		if pageData.Tag != "" {
+			itemList, err = list.BasicFilterToDoList(key, pageData.Tag)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		pageData.Items = list.SortedArray(itemList)
+		items := list.SortedArray(itemList)
+		if err := list.SortItems(items, pageData.Sort); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pageData.Items = list.Tree(items)
+		w.Header().Set("ETag", etag(version))
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(pageData.Items)
+			return
+		}
 		list.Logger.InfoContext(r.Context(), "Parse files")
-		tmpl, err := template.New("layout.html").ParseFiles(lp)
+		tmpl, err := template.New("layout.html").Funcs(template.FuncMap{"uid": func() string { return uid }}).ParseFiles(lp)
 		if err != nil {
 			list.Logger.ErrorContext(r.Context(), "error parsing list template")
 			return
@@ -264,15 +451,374 @@ var ProcessRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r
 	}
 })
 
+// ProcessSearchRequestWithoutActor returns the items on a list matching
+// ?q=, best match first
+var ProcessSearchRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+
+	found, err := list.BasicSearchToDoList(list.ListKey(uid, r.PathValue("list")), r.FormValue("q"))
+	switch {
+	case errors.Is(err, list.NotFoundErr):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, list.EmptySearchErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+})
+
+// ProcessUndoRequestWithoutActor undoes, on /todo/undo, or redoes, on
+// /todo/redo, the latest change to a users lists. it returns what was
+// changed and the list as it is now.
+var ProcessUndoRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+
+	undo := list.BasicUndo
+	if strings.HasSuffix(r.URL.Path, "/redo") {
+		undo = list.BasicRedo
+	}
+	op, err := undo(uid)
+	switch {
+	case errors.Is(err, list.NothingToUndoErr), errors.Is(err, list.NothingToRedoErr):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Op    string          `json:"op"`
+		Items []list.ToDoItem `json:"items"`
+	}{op, list.SortedArray(list.GetUserList(list.ListKey(uid, r.PathValue("list"))))})
+})
+
+// batchResponse is what a batch did, Error is why nothing changed
+type batchResponse struct {
+	Results []list.BatchResult `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// ProcessBatchRequestWithoutActor makes the changes in a JSON array of
+// operations all or nothing, returning what happened to each one.
+// operations without a uid or list are made to ?uid= and the list in the
+// path.
+var ProcessBatchRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+	ops := make([]list.BatchOp, 0)
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := list.BasicBatch(list.ListKey(uid, r.PathValue("list")), ops)
+	response := batchResponse{Results: results}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, list.NotFoundErr):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, list.AlreadyExistsErr), errors.Is(err, list.ChildrenErr):
+		w.WriteHeader(http.StatusConflict)
+	case err != nil:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err != nil {
+		list.Logger.ErrorContext(r.Context(), fmt.Sprintf("error applying batch %v", err))
+		response.Error = err.Error()
+	}
+	json.NewEncoder(w).Encode(response)
+})
+
+// the content type of each export format
+var exportTypes = map[string]string{
+	list.JSONFormat:     "application/json",
+	list.CSVFormat:      "text/csv; charset=utf-8",
+	list.MarkdownFormat: "text/markdown; charset=utf-8",
+	list.ToDoTxtFormat:  "text/plain; charset=utf-8",
+	list.ICalFormat:     "text/calendar; charset=utf-8",
+}
+
+// the file extension of each export format
+var exportExtensions = map[string]string{
+	list.JSONFormat:     ".json",
+	list.CSVFormat:      ".csv",
+	list.MarkdownFormat: ".md",
+	list.ToDoTxtFormat:  ".txt",
+	list.ICalFormat:     ".ics",
+}
+
+// ProcessExportRequestWithoutActor returns every list of ?uid=, or the list
+// in the path, in ?format= json, the default, csv, markdown, todotxt or
+// ical
+var ProcessExportRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	format := list.JSONFormat
+	if f := r.URL.Query().Get("format"); f != "" {
+		var err error
+		if format, err = list.ParseFormat(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	exportListsWithoutActor(w, r, format, "attachment")
+})
+
+// ProcessCalendarRequestWithoutActor returns every list of ?uid=, or the
+// list in the path, as an iCalendar feed a calendar app can subscribe to
+var ProcessCalendarRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	exportListsWithoutActor(w, r, list.ICalFormat, "inline")
+})
+
+// exportListsWithoutActor writes the lists asked for in format, as an
+// attachment to save or inline
+func exportListsWithoutActor(w http.ResponseWriter, r *http.Request, format string, disposition string) {
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+	name := r.PathValue("list")
+	if name == "" {
+		name = list.AllLists
+	}
+
+	items, err := list.BasicExport(uid, name)
+	if errors.Is(err, list.NotFoundErr) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", exportTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, "todo"+exportExtensions[format]))
+	if err := list.WriteItems(w, format, items); err != nil {
+		list.Logger.ErrorContext(r.Context(), fmt.Sprintf("error writing export %v", err))
+	}
+}
+
+// ProcessImportRequestWithoutActor adds the items in the body, in ?format=
+// json, the default, csv, markdown, todotxt or ical, to the lists they
+// name or the list in the path, skipping duplicates. it returns what was
+// added and skipped, and with ?dryrun=true only what would be.
+var ProcessImportRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+	format := r.FormValue("format")
+	if format == "" {
+		format = list.JSONFormat
+	}
+	items, err := list.ReadItems(r.Body, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryrun"))
+
+	result, err := list.BasicImport(uid, r.PathValue("list"), items, dryRun)
+	if errors.Is(err, list.InvalidListNameErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		list.Logger.ErrorContext(r.Context(), fmt.Sprintf("error importing items %v", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+})
+
+// ProcessAuditRequestWithoutActor returns the events in the audit log, oldest first. it
+// needs the admin token and takes ?uid=, ?actor=, ?op=, ?from=, ?to= and
+// ?limit= to narrow them down.
+var ProcessAuditRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(*adminTokenFlag)) != 1 {
+		http.Error(w, "not allowed", http.StatusForbidden)
+		return
+	}
+	filter := list.AuditFilter{Uid: r.FormValue("uid"), Actor: r.FormValue("actor"), Op: r.FormValue("op")}
+	for _, v := range []struct {
+		value string
+		to    *time.Time
+	}{{r.FormValue("from"), &filter.From}, {r.FormValue("to"), &filter.To}} {
+		if v.value == "" {
+			continue
+		}
+		var err error
+		if *v.to, err = list.ParseDue(v.value, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", limit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := list.QueryAudit(filter)
+	if err != nil {
+		list.Logger.ErrorContext(r.Context(), fmt.Sprintf("error reading audit log %v", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+})
+
+// ProcessReminderRequestWithoutActor returns the latest reminders that
+// have come due for a user, oldest first
+var ProcessReminderRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Reminders.Events(uid))
+})
+
+// ProcessListRequestWithoutActor manages a users lists. GET returns the
+// names of their lists, POST with {"name": "..."} creates one, and on
+// /todo/lists/{list} PATCH with {"name": "..."} renames it and DELETE
+// deletes it.
+var ProcessListRequestWithoutActor = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	uid := "Anonymous User"
+	err := r.ParseForm()
+	if err == nil {
+		uid = r.FormValue("uid")
+	}
+
+	var body = make(map[string]string)
+	if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err = list.BasicCreateList(uid, body["name"])
+	case http.MethodPatch:
+		err = list.BasicRenameList(uid, r.PathValue("list"), body["name"])
+	case http.MethodDelete:
+		err = list.BasicDeleteList(uid, r.PathValue("list"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case errors.Is(err, list.NotFoundErr):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, list.AlreadyExistsErr):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lists, err := list.BasicLists(uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(lists)
+})
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 
-	err := list.BasicLoadToDoList()
+	store, err := list.NewStore(*storeFlag, "todo.txt")
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error opening store", "details", err)
+		return
+	}
+	list.UseStore(store)
+
+	policy, err := list.ParseChildPolicy(*subtasksFlag)
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error parsing command line", "details", err)
+		return
+	}
+	list.SetChildPolicy(policy)
+
+	err = list.BasicLoadToDoList()
 	if err != nil {
 		list.Logger.ErrorContext(ctx, "Error Loading todo List", "details", err)
 		return
 	}
 
+	list.AddNotifier(Reminders)
+	if *webhookFlag != "" {
+		list.AddNotifier(list.NewWebhookNotifier(*webhookFlag))
+	}
+	if *remindLogFlag != "" {
+		list.AddNotifier(list.NewFileNotifier(*remindLogFlag))
+	}
+	go list.ProcessReminders()
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
@@ -291,7 +837,28 @@ func main() {
 	fs := http.FileServer(http.Dir("./static"))
 
 	mux.Handle("/todo", TracingMiddleware(ProcessRequestWithoutActor))
+	mux.Handle("/todo/search", TracingMiddleware(ProcessSearchRequestWithoutActor))
+	mux.Handle("/todo/reminders", TracingMiddleware(ProcessReminderRequestWithoutActor))
+	mux.Handle("/todo/undo", TracingMiddleware(ProcessUndoRequestWithoutActor))
+	mux.Handle("/todo/redo", TracingMiddleware(ProcessUndoRequestWithoutActor))
+	mux.Handle("/todo/batch", TracingMiddleware(ProcessBatchRequestWithoutActor))
+	mux.Handle("/todo/export", TracingMiddleware(ProcessExportRequestWithoutActor))
+	mux.Handle("/todo/import", TracingMiddleware(ProcessImportRequestWithoutActor))
+	mux.Handle("/todo/calendar.ics", TracingMiddleware(ProcessCalendarRequestWithoutActor))
+	mux.Handle("/todo/lists", TracingMiddleware(ProcessListRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}", TracingMiddleware(ProcessListRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/items", TracingMiddleware(ProcessRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/search", TracingMiddleware(ProcessSearchRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/undo", TracingMiddleware(ProcessUndoRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/redo", TracingMiddleware(ProcessUndoRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/batch", TracingMiddleware(ProcessBatchRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/export", TracingMiddleware(ProcessExportRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/import", TracingMiddleware(ProcessImportRequestWithoutActor))
+	mux.Handle("/todo/lists/{list}/calendar.ics", TracingMiddleware(ProcessCalendarRequestWithoutActor))
 	mux.Handle("/todo/", http.StripPrefix("/todo/", fs))
+	if *adminTokenFlag != "" {
+		mux.Handle("/admin/audit", TracingMiddleware(ProcessAuditRequestWithoutActor))
+	}
 	fmt.Printf("\nListening on port 8000\n")
 	if err := http.ListenAndServe(":8000", mux); err != nil {
 		fmt.Printf("error running http server: %s\n", err)
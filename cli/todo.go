@@ -2,19 +2,56 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	list "github.com/simonedz197/ToDoListStore"
 )
 
 var uidFlag = flag.String("uid", "", "owner of the todo list e.g. -uid simon")
+var storeFlag = flag.String("store", list.FileBackend, "where todo lists are kept: memory, file, todotxt or db e.g. -store db")
 var addFlag = flag.String("add", "", "add the todo list entry e.g. -add \"buy milk\"")
-var updateFlag = flag.String("update", "", "update the todo list entry e.g. -update \"buy milk\" \"buy 2 pints of milk\"")
-var deleteFlag = flag.String("delete", "", "delete the todo list entry e.g. -delete \"buy milk\"\nUse delete \"*\" to delete all")
+var updateFlag = flag.String("update", "", "update the todo list entry by number, id or text e.g. -update 1 \"buy 2 pints of milk\"")
+var deleteFlag = flag.String("delete", "", "delete the todo list entry by number, id or text e.g. -delete \"buy milk\"\nUse delete \"*\" to delete all")
+var doneFlag = flag.String("done", "", "mark the todo list entry as complete by number, id or text e.g. -done 1")
+var backupsFlag = flag.Bool("backups", false, "list the backups of the todo list file")
+var restoreFlag = flag.String("restore", "", "roll the todo list file back to a backup by number or name from -backups e.g. -restore 1")
+var listFlag = flag.String("list", "", "the todo list to work on, the default list if not set e.g. -list groceries")
+var listsFlag = flag.Bool("lists", false, "show the names of your todo lists")
+var createListFlag = flag.String("createlist", "", "create a new todo list e.g. -createlist groceries")
+var renameListFlag = flag.String("renamelist", "", "rename a todo list e.g. -renamelist groceries shopping")
+var deleteListFlag = flag.String("deletelist", "", "delete a todo list and everything on it e.g. -deletelist groceries")
+var parentFlag = flag.String("parent", "", "with -add or -move, the entry by number, id or text to put it under e.g. -add \"run tests\" -parent 1")
+var moveFlag = flag.String("move", "", "move the todo list entry by number, id or text under -parent, or to the top level without it e.g. -move 3 -parent 1\nFollowed by a position it moves the entry there instead: a number, first, last, before or after another entry e.g. -move 3 \"before 1\"")
+var priorityFlag = flag.String("priority", "", "set the priority of the todo list entry by number, id or text, A the highest to Z, or none e.g. -priority 1 A")
+var sortFlag = flag.String("sort", list.ManualOrder, "the order to list the todo list entries in: manual, priority or due e.g. -sort priority")
+var subtasksFlag = flag.String("subtasks", "cascade", "what completing, moving or deleting an entry does to its sub-tasks: cascade or block")
+var searchFlag = flag.String("search", "", "find todo list entries by their words, best match first, allowing for typos e.g. -search milk")
+var tagFlag = flag.String("tag", "", "only list the todo list entries with this tag e.g. -tag work")
+var reopenFlag = flag.String("reopen", "", "mark a completed todo list entry as not done by number, id or text e.g. -reopen 1")
+var dueFlag = flag.String("due", "", "set when the todo list entry by number, id or text is due: today, tomorrow, 2026-10-21, 2026-10-21 09:30 or none e.g. -due 1 tomorrow")
+var remindFlag = flag.String("remind", "", "remind about the todo list entry by number, id or text this long before it is due, or none e.g. -remind 1 \"1d,30m\"")
+var repeatFlag = flag.String("repeat", "", "make the todo list entry by number, id or text recur: daily, weekly mon,thu, monthly 15, every 3 days or never e.g. -repeat 1 \"weekly tue\"")
+var undoFlag = flag.Bool("undo", false, "undo the last change to your todo lists, again to go further back e.g. -undo")
+var redoFlag = flag.Bool("redo", false, "redo the last change undone with -undo e.g. -redo")
+var batchFlag = flag.String("batch", "", "make the changes in a JSON file all or nothing, - reads them from stdin e.g. -batch changes.json\nEach change is like {\"op\": \"add\", \"item\": \"buy milk\"}, with \"uid\" and \"list\" defaulting to -uid and -list")
+var importFlag = flag.String("import", "", "add the entries in a file to the todo list, or the lists they name, skipping duplicates, - reads them from stdin e.g. -import list.md")
+var exportFlag = flag.String("export", "", "write every todo list, or just -list, to a file e.g. -export backup.json, or -export todo.ics for a calendar")
+var formatFlag = flag.String("format", "", "with -import or -export, the file format: json, csv, markdown, todotxt or ical, by default from the file extension e.g. -format csv")
+var dryRunFlag = flag.Bool("dryrun", false, "with -import, show what would be added and skipped without adding anything e.g. -import list.csv -dryrun")
+var auditFlag = flag.Bool("audit", false, "show who changed what and when, for -uid or everyone, narrowed with -op, -from and -to e.g. -audit -op delete -from today")
+var opFlag = flag.String("op", "", "with -audit, only show this kind of change: add, update, delete, done, reopen, tag, untag, move, repeat, due, remind, reorder, priority, import, create list, rename list, delete list, undo, redo or restore")
+var fromFlag = flag.String("from", "", "with -audit, only show changes from this date or time on e.g. -from 2026-10-01")
+var toFlag = flag.String("to", "", "with -audit, only show changes before this date or time e.g. -to \"2026-10-01 12:00\"")
 
 type RequestId string
 type UserId string
@@ -27,15 +64,225 @@ func dummyContext() context.Context {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, reflect.TypeOf(request_id), request_id)
 	ctx = context.WithValue(ctx, reflect.TypeOf(user_id), user_id)
+	ctx = list.WithRequestId(ctx, string(request_id))
+	// changes are audited as made by whoever runs the command
+	if me, err := user.Current(); err == nil {
+		ctx = list.WithActor(ctx, me.Username)
+	}
 	return ctx
 }
 
+// checkbox shown next to each item in the list output
+func doneBox(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// tags shown after an item in the list output
+func tagList(tags []string) string {
+	out := ""
+	for _, v := range tags {
+		out += " #" + v
+	}
+	return out
+}
+
+// how often an item repeats, when it is due and its reminders, shown after
+// it in the list output
+func dueInfo(item list.ToDoItem) string {
+	info := make([]string, 0, 3)
+	if item.Repeat != "" {
+		info = append(info, item.Repeat)
+	}
+	if !item.Due.IsZero() {
+		layout := "Mon 02 Jan 15:04"
+		if item.Due.Hour() == 0 && item.Due.Minute() == 0 {
+			layout = "Mon 02 Jan"
+		}
+		info = append(info, "due "+item.Due.Format(layout))
+	}
+	if len(item.Reminders) > 0 {
+		info = append(info, "remind "+strings.Join(item.Reminders, ","))
+	}
+	if len(info) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(info, ", ") + "}"
+}
+
+// priorityMark shows the priority of an item before its text
+func priorityMark(item list.ToDoItem) string {
+	if item.Priority == "" {
+		return ""
+	}
+	return "(" + item.Priority + ") "
+}
+
+// printItem shows an item on a line of the list output
+func printItem(item list.ToDoItem, depth int) {
+	fmt.Printf("%s%d. %s %s%s%s%s (%s)\n", strings.Repeat("   ", depth), item.Id, doneBox(item.Done), priorityMark(item), item.Item, tagList(item.Tags), dueInfo(item), item.ItemId)
+}
+
+// printTree shows items indented under the item they are a sub-task of
+func printTree(nodes []list.ToDoNode, depth int) {
+	for _, v := range nodes {
+		printItem(v.ToDoItem, depth)
+		printTree(v.Children, depth+1)
+	}
+}
+
+// chooseBackup turns the number shown by -backups into the backup name.
+// anything else is taken to be the name itself
+func chooseBackup(choice string) (string, error) {
+	backups, err := list.ListBackups()
+	if err != nil {
+		return "", err
+	}
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n < 1 || n > len(backups) {
+			return "", list.BackupNotFoundErr
+		}
+		return backups[n-1], nil
+	}
+	return choice, nil
+}
+
+// readBatch reads the changes in a batch file, or stdin when it is -
+func readBatch(filename string) ([]list.BatchOp, error) {
+	in := os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+	ops := make([]list.BatchOp, 0)
+	if err := json.NewDecoder(in).Decode(&ops); err != nil {
+		return nil, fmt.Errorf("%s %w", filename, err)
+	}
+	return ops, nil
+}
+
+// fileFormat returns -format, or the format of filename from its extension
+func fileFormat(filename string) string {
+	if *formatFlag != "" {
+		return *formatFlag
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return list.JSONFormat
+	case ".csv":
+		return list.CSVFormat
+	case ".md", ".markdown":
+		return list.MarkdownFormat
+	case ".ics", ".ical":
+		return list.ICalFormat
+	}
+	return list.ToDoTxtFormat
+}
+
+// readImport reads the entries in a file, or stdin when it is -
+func readImport(filename string) ([]list.ExportItem, error) {
+	in := os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+	items, err := list.ReadItems(in, fileFormat(filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", filename, err)
+	}
+	return items, nil
+}
+
+// writeExport writes items to a file
+func writeExport(filename string, items []list.ExportItem) error {
+	if _, err := list.ParseFormat(fileFormat(filename)); err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := list.WriteItems(file, fileFormat(filename), items); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// printImport shows what an import added, +, and skipped as a duplicate, =
+func printImport(result list.ImportResult, dryRun bool) {
+	if dryRun {
+		fmt.Printf("\nIMPORT DRY RUN\n--------------\n")
+	} else {
+		fmt.Printf("\nIMPORTED\n--------\n")
+	}
+	for _, v := range []struct {
+		mark  string
+		items []list.ExportItem
+	}{{"+", result.Added}, {"=", result.Skipped}} {
+		for _, item := range v.items {
+			fmt.Printf("%s %s", v.mark, item.Item)
+			if item.List != "" {
+				fmt.Printf(" (list %s)", item.List)
+			}
+			fmt.Printf("\n")
+		}
+	}
+	fmt.Printf("%d added, %d skipped as duplicates\n", len(result.Added), len(result.Skipped))
+}
+
+// printAuditEvent shows an audit event and what it changed, + for an item
+// added, - for one deleted and ~ for one changed
+func printAuditEvent(event list.AuditEvent) {
+	op := event.Op
+	if event.Detail != "" {
+		op += " " + event.Detail
+	}
+	fmt.Printf("%d. %s %s by %s for %s", event.Seq, event.Time.Local().Format("2006-01-02 15:04:05"), op, event.Actor, event.Uid)
+	if event.RequestId != "" {
+		fmt.Printf(" (%s)", event.RequestId)
+	}
+	fmt.Printf("\n")
+	for _, v := range event.Created {
+		fmt.Printf("    + list %s\n", v)
+	}
+	for _, v := range event.Changes {
+		where := ""
+		if v.List != "" {
+			where = v.List + ": "
+		}
+		switch {
+		case v.Before == nil:
+			fmt.Printf("    + %s%s %s\n", where, doneBox(v.After.Done), v.After.Item)
+		case v.After == nil:
+			fmt.Printf("    - %s%s %s\n", where, doneBox(v.Before.Done), v.Before.Item)
+		default:
+			fmt.Printf("    ~ %s%s %s -> %s %s\n", where, doneBox(v.Before.Done), v.Before.Item, doneBox(v.After.Done), v.After.Item)
+		}
+	}
+	for _, v := range event.Deleted {
+		fmt.Printf("    - list %s\n", v)
+	}
+}
+
 // return names of all flags passed in
 // we are hoping there is only 1
 func flagsPassed() []string {
 	name := ""
 	flag.Visit(func(f *flag.Flag) {
-		if f.Name != "uid" {
+		switch f.Name {
+		case "uid", "store", "tag", "list", "parent", "subtasks", "op", "from", "to", "sort", "format", "dryrun":
+		default:
 			name += f.Name + "|"
 		}
 	})
@@ -56,6 +303,20 @@ func main() {
 
 	flag.Parse()
 
+	store, err := list.NewStore(*storeFlag, "todo.txt")
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error opening store", "details", err)
+		return
+	}
+	list.UseStore(store)
+
+	policy, err := list.ParseChildPolicy(*subtasksFlag)
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error parsing command line", "details", err)
+		return
+	}
+	list.SetChildPolicy(policy)
+
 	flagsSet := flagsPassed()
 
 	if len(flagsSet) > 2 {
@@ -89,7 +350,7 @@ func main() {
 
 	switch flagsSet[0] {
 	case "add":
-		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, JobType: list.AddData, KeyValue: *addFlag, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.AddData, KeyValue: *addFlag, AltValue: *parentFlag, ReturnChannel: make(chan list.ReturnChannelData)}
 		list.DataJobQueue <- data
 		returnVal, ok := <-data.ReturnChannel
 		if ok {
@@ -99,7 +360,7 @@ func main() {
 			}
 		}
 	case "delete":
-		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, JobType: list.DeleteData, KeyValue: *deleteFlag, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.DeleteData, KeyValue: *deleteFlag, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 		list.DataJobQueue <- data
 		returnVal, ok := <-data.ReturnChannel
 		if ok {
@@ -112,7 +373,7 @@ func main() {
 		if flag.NArg() == 0 {
 			fmt.Printf("\nyou need to enter the value to update to")
 		}
-		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, JobType: list.UpdateData, KeyValue: *updateFlag, AltValue: flag.Arg(0), ReturnChannel: make(chan list.ReturnChannelData)}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.UpdateData, KeyValue: *updateFlag, AltValue: flag.Arg(0), ReturnChannel: make(chan list.ReturnChannelData)}
 		list.DataJobQueue <- data
 		returnVal, ok := <-data.ReturnChannel
 		if ok {
@@ -121,8 +382,305 @@ func main() {
 				return
 			}
 		}
+	case "backups":
+		backups, err := list.ListBackups()
+		if err != nil {
+			list.Logger.ErrorContext(ctx, "Error listing backups", "details", err)
+			return
+		}
+		fmt.Printf("\nBACKUPS\n-------\n")
+		for i, v := range backups {
+			fmt.Printf("%d. %s\n", i+1, v)
+		}
+		return
+	case "restore":
+		backup, err := chooseBackup(*restoreFlag)
+		if err != nil {
+			list.Logger.ErrorContext(ctx, "Error restoring backup", "details", err)
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.RestoreData, KeyValue: "todo.txt", AltValue: backup, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error restoring backup", "details", returnVal.Err)
+				return
+			}
+		}
+		fmt.Printf("\nrestored %s\n", backup)
+	case "lists":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, JobType: list.FetchListsData, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error listing todo lists", "details", returnVal.Err)
+				return
+			}
+			fmt.Printf("\nTODO LISTS\n----------\n")
+			for _, v := range returnVal.Lists {
+				fmt.Printf("%s\n", v)
+			}
+		}
+		return
+	case "createlist", "renamelist", "deletelist":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, ReturnChannel: make(chan list.ReturnChannelData)}
+		switch flagsSet[0] {
+		case "createlist":
+			data.JobType, data.List = list.CreateListData, *createListFlag
+		case "renamelist":
+			if flag.NArg() == 0 {
+				fmt.Printf("\nyou need to enter the new name of the list")
+				return
+			}
+			data.JobType, data.List, data.AltValue = list.RenameListData, *renameListFlag, flag.Arg(0)
+		case "deletelist":
+			data.JobType, data.List = list.DeleteListData, *deleteListFlag
+		}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error changing todo lists", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+			fmt.Printf("\nTODO LISTS\n----------\n")
+			for _, v := range returnVal.Lists {
+				fmt.Printf("%s\n", v)
+			}
+		}
+		return
+	case "move":
+		// a position reorders the entry, -parent or neither moves it in the tree
+		position := strings.Join(flag.Args(), " ")
+		if *parentFlag != "" || position == "" {
+			data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.MoveData, KeyValue: *moveFlag, AltValue: *parentFlag, ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error moving to do item", "details", returnVal.Err)
+					return
+				}
+			}
+		}
+		if position != "" {
+			data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.ReorderData, KeyValue: *moveFlag, AltValue: position, ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error reordering to do item", "details", returnVal.Err)
+					fmt.Printf("\n%v\n", returnVal.Err)
+					return
+				}
+			}
+		}
+	case "priority":
+		if flag.NArg() == 0 {
+			fmt.Printf("\nyou need to enter the priority, or none")
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.PriorityData, KeyValue: *priorityFlag, AltValue: flag.Arg(0), ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error setting to do item priority", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+		}
+	case "done":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.CompleteData, KeyValue: *doneFlag, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error completing to do item", "details", returnVal.Err)
+				return
+			}
+		}
+	case "repeat":
+		if flag.NArg() == 0 {
+			fmt.Printf("\nyou need to enter the rule to repeat on, or never")
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.RepeatData, KeyValue: *repeatFlag, AltValue: flag.Arg(0), ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error setting to do item repeat", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+		}
+	case "search":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.SearchData, KeyValue: *searchFlag, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error searching to do list", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+			fmt.Printf("\nFOUND %d FOR %q\n----------\n", len(returnVal.Found), *searchFlag)
+			for _, v := range returnVal.Found {
+				printItem(v.ToDoItem, 0)
+			}
+		}
+		return
+	case "due", "remind":
+		if flag.NArg() == 0 {
+			fmt.Printf("\nyou need to enter the %s value, or none", flagsSet[0])
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.DueData, KeyValue: *dueFlag, AltValue: flag.Arg(0), ReturnChannel: make(chan list.ReturnChannelData)}
+		if flagsSet[0] == "remind" {
+			data.JobType, data.KeyValue = list.RemindData, *remindFlag
+		}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error setting to do item "+flagsSet[0], "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+		}
+	case "batch":
+		ops, err := readBatch(*batchFlag)
+		if err != nil {
+			list.Logger.ErrorContext(ctx, "Error reading batch", "details", err)
+			fmt.Printf("\n%v\n", err)
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.BatchData, Batch: ops, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			fmt.Printf("\nBATCH\n-----\n")
+			for i, v := range returnVal.Results {
+				fmt.Printf("%d. %s %s", i+1, v.Op, v.Status)
+				if v.Error != "" {
+					fmt.Printf(", %s", v.Error)
+				}
+				fmt.Printf("\n")
+			}
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error applying batch", "details", returnVal.Err)
+				fmt.Printf("\nnothing changed, %v\n", returnVal.Err)
+				return
+			}
+		}
+	case "import":
+		items, err := readImport(*importFlag)
+		if err != nil {
+			list.Logger.ErrorContext(ctx, "Error reading import", "details", err)
+			fmt.Printf("\n%v\n", err)
+			return
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.ImportData, Items: items, DryRun: *dryRunFlag, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error importing to do items", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+			printImport(returnVal.Imported, *dryRunFlag)
+			if *dryRunFlag {
+				return
+			}
+		}
+	case "export":
+		name := *listFlag
+		if name == "" {
+			name = list.AllLists
+		}
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: name, JobType: list.ExportData, ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error exporting to do list", "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+			items := returnVal.Items
+			if err := writeExport(*exportFlag, items); err != nil {
+				list.Logger.ErrorContext(ctx, "Error exporting to do list", "details", err)
+				fmt.Printf("\n%v\n", err)
+				return
+			}
+			fmt.Printf("\nexported %d to %s\n", len(items), *exportFlag)
+		}
+		return
+	case "audit":
+		filter := list.AuditFilter{Uid: *uidFlag, Op: *opFlag}
+		for _, v := range []struct {
+			value string
+			to    *time.Time
+		}{{*fromFlag, &filter.From}, {*toFlag, &filter.To}} {
+			if v.value == "" {
+				continue
+			}
+			if *v.to, err = list.ParseDue(v.value, time.Now()); err != nil {
+				fmt.Printf("\n%v\n", err)
+				return
+			}
+		}
+		events, err := list.QueryAudit(filter)
+		if err != nil {
+			list.Logger.ErrorContext(ctx, "Error reading audit log", "details", err)
+			fmt.Printf("\n%v\n", err)
+			return
+		}
+		fmt.Printf("\nAUDIT\n-----\n")
+		for _, v := range events {
+			printAuditEvent(v)
+		}
+		return
+	case "undo", "redo":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.UndoData, ReturnChannel: make(chan list.ReturnChannelData)}
+		if flagsSet[0] == "redo" {
+			data.JobType = list.RedoData
+		}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error running "+flagsSet[0], "details", returnVal.Err)
+				fmt.Printf("\n%v\n", returnVal.Err)
+				return
+			}
+			if flagsSet[0] == "undo" {
+				fmt.Printf("\nundid %s\n", returnVal.Op)
+			} else {
+				fmt.Printf("\nredid %s\n", returnVal.Op)
+			}
+		}
+	case "reopen":
+		data := list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.ReopenData, KeyValue: *reopenFlag, AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+		list.DataJobQueue <- data
+		returnVal, ok := <-data.ReturnChannel
+		if ok {
+			if returnVal.Err != nil {
+				list.Logger.ErrorContext(ctx, "Error reopening to do item", "details", returnVal.Err)
+				return
+			}
+		}
+	}
+	data = list.DataStoreJob{Context: ctx, Uid: *uidFlag, List: *listFlag, JobType: list.FetchData, KeyValue: "", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+	if *tagFlag != "" {
+		data.JobType = list.FilterData
+		data.KeyValue = *tagFlag
 	}
-	data = list.DataStoreJob{Context: ctx, Uid: *uidFlag, JobType: list.FetchData, KeyValue: "", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 	list.DataJobQueue <- data
 	returnVal, ok = <-data.ReturnChannel
 	if ok {
@@ -130,10 +688,17 @@ func main() {
 			list.Logger.ErrorContext(ctx, "Error listing to do items", "details", returnVal.Err)
 			return
 		}
-		fmt.Printf("\nTO DO LIST\n----------\n")
-		for _, v := range list.SortedArray(returnVal.List) {
-			fmt.Printf("%d. %s\n", v.Id, v.Item)
+		items := list.SortedArray(returnVal.List)
+		if err := list.SortItems(items, *sortFlag); err != nil {
+			fmt.Printf("\n%v\n", err)
+			return
+		}
+		if *listFlag != "" {
+			fmt.Printf("\nTO DO LIST %s\n----------\n", *listFlag)
+		} else {
+			fmt.Printf("\nTO DO LIST\n----------\n")
 		}
+		printTree(list.Tree(items), 0)
 	}
 
 }
@@ -0,0 +1,11 @@
+module tut2/todo/repl
+
+go 1.24.2
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/simonedz197/ToDoListStore v0.0.0-20250515152709-9afa9a1503a4
+)
+
+// the store is kept in this repository, alongside the frontends using it
+replace github.com/simonedz197/ToDoListStore => ../ToDoListStore
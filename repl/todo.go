@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -13,6 +15,9 @@ import (
 	list "github.com/simonedz197/ToDoListStore"
 )
 
+var storeFlag = flag.String("store", list.FileBackend, "where todo lists are kept: memory, file, todotxt or db e.g. -store db")
+var subtasksFlag = flag.String("subtasks", "cascade", "what completing, moving or deleting an item does to its sub-tasks: cascade or block")
+
 func dummyContext() context.Context {
 	request_id := uuid.NewString()
 	user_id := "edz197"
@@ -27,14 +32,98 @@ func stripnl(s string) string {
 	return strings.ToLower(s[:len(s)-1])
 }
 
+// tags shown after an item in the list output
+func tagList(tags []string) string {
+	out := ""
+	for _, v := range tags {
+		out += " #" + v
+	}
+	return out
+}
+
+// how often an item repeats, when it is due and its reminders, shown after
+// it in the list output
+func dueInfo(item list.ToDoItem) string {
+	info := make([]string, 0, 3)
+	if item.Repeat != "" {
+		info = append(info, item.Repeat)
+	}
+	if !item.Due.IsZero() {
+		layout := "Mon 02 Jan 15:04"
+		if item.Due.Hour() == 0 && item.Due.Minute() == 0 {
+			layout = "Mon 02 Jan"
+		}
+		info = append(info, "due "+item.Due.Format(layout))
+	}
+	if len(item.Reminders) > 0 {
+		info = append(info, "remind "+strings.Join(item.Reminders, ","))
+	}
+	if len(info) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(info, ", ") + "}"
+}
+
+// priorityMark shows the priority of an item before its text
+func priorityMark(item list.ToDoItem) string {
+	if item.Priority == "" {
+		return ""
+	}
+	return "(" + item.Priority + ") "
+}
+
+// printItem shows an item on a line of the list output
+func printItem(item list.ToDoItem, depth int) {
+	fmt.Printf("%s%d. %s %s%s%s%s (%s)\n", strings.Repeat("   ", depth), item.Id, doneBox(item.Done), priorityMark(item), item.Item, tagList(item.Tags), dueInfo(item), item.ItemId)
+}
+
+// printTree shows items indented under the item they are a sub-task of
+func printTree(nodes []list.ToDoNode, depth int) {
+	for _, v := range nodes {
+		printItem(v.ToDoItem, depth)
+		printTree(v.Children, depth+1)
+	}
+}
+
+// checkbox shown next to each item in the list output
+func doneBox(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
 func main() {
 	// setup a dummy context
 	// we should get this passed in eventually
 	ctx := dummyContext()
 
+	flag.Parse()
+
+	store, err := list.NewStore(*storeFlag, "todo.txt")
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error opening store", "details", err)
+		return
+	}
+	list.UseStore(store)
+
+	policy, err := list.ParseChildPolicy(*subtasksFlag)
+	if err != nil {
+		list.Logger.ErrorContext(ctx, "Error parsing command line", "details", err)
+		return
+	}
+	list.SetChildPolicy(policy)
+
 	// start the job queue prcessor
 	go list.ProcessDataJobs()
 
+	// reminders are printed as they come due, in among the prompts
+	list.AddNotifier(list.NotifierFunc(func(ctx context.Context, event list.ReminderEvent) error {
+		fmt.Printf("\n\n*** reminder for %s: %s ***\n\n", event.Uid, event)
+		return nil
+	}))
+	go list.ProcessReminders()
+
 	// load data
 	data := list.DataStoreJob{Context: ctx, Uid: "", JobType: list.LoadData, KeyValue: "todo.txt", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 	list.DataJobQueue <- data
@@ -81,6 +170,9 @@ func main() {
 
 	fmt.Printf("\nctrl+c to quit\n\n")
 
+	// the list commands work on, changed with use
+	listName := list.DefaultList
+
 	for {
 		// do this forever until ctrl+c is entered
 		reader := bufio.NewReader(os.Stdin)
@@ -90,7 +182,7 @@ func main() {
 		if uid == "" {
 			uid = "Anonympus User"
 		}
-		fmt.Printf("\nEnter Command (add/upd/del/lst) : ")
+		fmt.Printf("\nEnter Command for list %s (add/sub/move/mv/pri/upd/del/done/reopen/repeat/due/remind/tag/untag/lst/find/undo/redo/use/lists/renlist/dellist) : ", listName)
 		cmd, _ := reader.ReadString('\n')
 		cmd = stripnl(cmd)
 		if cmd == "" {
@@ -104,7 +196,7 @@ func main() {
 		case "add":
 			fmt.Printf("\nEnter todo Item to %s : ", cmd)
 			item, _ = reader.ReadString('\n')
-			data := list.DataStoreJob{Context: ctx, Uid: uid, JobType: list.AddData, KeyValue: stripnl(item), AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.AddData, KeyValue: stripnl(item), AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 			list.DataJobQueue <- data
 			returnVal, ok := <-data.ReturnChannel
 			if ok {
@@ -113,10 +205,34 @@ func main() {
 					fmt.Printf("\n\ncould not add. see log for details\n\n")
 				}
 			}
+		case "sub", "move":
+			prompt := "add a sub-task to"
+			if cmd == "move" {
+				fmt.Printf("\nEnter todo Item number, id or text to move : ")
+				item, _ = reader.ReadString('\n')
+				prompt = "move it under, or nothing for the top level,"
+			}
+			fmt.Printf("\nEnter todo Item number, id or text to %s : ", prompt)
+			parent, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.MoveData, KeyValue: stripnl(item), AltValue: stripnl(parent), ReturnChannel: make(chan list.ReturnChannelData)}
+			if cmd == "sub" {
+				fmt.Printf("\nnow enter the sub-task : ")
+				item, _ = reader.ReadString('\n')
+				data.JobType = list.AddData
+				data.KeyValue = stripnl(item)
+			}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item parent", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. %v\n\n", cmd, returnVal.Err)
+				}
+			}
 		case "del":
-			fmt.Printf("\nEnter todo Item to %s : ", cmd)
+			fmt.Printf("\nEnter todo Item number, id or text to %s : ", cmd)
 			item, _ = reader.ReadString('\n')
-			data := list.DataStoreJob{Context: ctx, Uid: uid, JobType: list.DeleteData, KeyValue: stripnl(item), AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.DeleteData, KeyValue: stripnl(item), AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
 			list.DataJobQueue <- data
 			returnVal, ok := <-data.ReturnChannel
 			if ok {
@@ -126,11 +242,11 @@ func main() {
 				}
 			}
 		case "upd":
-			fmt.Printf("\nEnter todo Item to replace : ")
+			fmt.Printf("\nEnter todo Item number, id or text to replace : ")
 			item, _ = reader.ReadString('\n')
 			fmt.Printf("\nnow enter todo item to replace with : ")
 			replaceWith, _ = reader.ReadString('\n')
-			data := list.DataStoreJob{Context: ctx, Uid: uid, JobType: list.UpdateData, KeyValue: stripnl(item), AltValue: stripnl(replaceWith), ReturnChannel: make(chan list.ReturnChannelData)}
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.UpdateData, KeyValue: stripnl(item), AltValue: stripnl(replaceWith), ReturnChannel: make(chan list.ReturnChannelData)}
 			list.DataJobQueue <- data
 			returnVal, ok := <-data.ReturnChannel
 			if ok {
@@ -139,18 +255,207 @@ func main() {
 					fmt.Printf("\n\ncould not update. see log for details\n\n")
 				}
 			}
+		case "done", "reopen":
+			jobType := list.JobType(list.CompleteData)
+			if cmd == "reopen" {
+				jobType = list.ReopenData
+			}
+			fmt.Printf("\nEnter todo Item number, id or text to mark %s : ", cmd)
+			item, _ = reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: jobType, KeyValue: stripnl(item), AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item status", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not mark %s. see log for details\n\n", cmd)
+				}
+			}
+		case "repeat":
+			fmt.Printf("\nEnter todo Item number, id or text to %s : ", cmd)
+			item, _ = reader.ReadString('\n')
+			fmt.Printf("\nnow enter how often, daily, weekly mon,thu, monthly 15, every 3 days or never : ")
+			rule, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.RepeatData, KeyValue: stripnl(item), AltValue: stripnl(rule), ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item repeat", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. %v\n\n", cmd, returnVal.Err)
+				}
+			}
+		case "mv", "pri":
+			jobType := list.JobType(list.ReorderData)
+			prompt := "where to put it, 3, first, last, before 2 or after milk"
+			if cmd == "pri" {
+				jobType = list.PriorityData
+				prompt = "its priority, A the highest to Z, or none"
+			}
+			fmt.Printf("\nEnter todo Item number, id or text to %s : ", cmd)
+			item, _ = reader.ReadString('\n')
+			fmt.Printf("\nnow enter %s : ", prompt)
+			value, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: jobType, KeyValue: stripnl(item), AltValue: stripnl(value), ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item "+cmd, "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. %v\n\n", cmd, returnVal.Err)
+				}
+			}
+		case "due", "remind":
+			jobType := list.JobType(list.DueData)
+			prompt := "when it is due, today, tomorrow, 2026-10-21, 2026-10-21 09:30 or none"
+			if cmd == "remind" {
+				jobType = list.RemindData
+				prompt = "how long before it is due to remind you, like 1d,30m, or none"
+			}
+			fmt.Printf("\nEnter todo Item number, id or text to set %s : ", cmd)
+			item, _ = reader.ReadString('\n')
+			fmt.Printf("\nnow enter %s : ", prompt)
+			value, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: jobType, KeyValue: stripnl(item), AltValue: stripnl(value), ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item "+cmd, "details", returnVal.Err)
+					fmt.Printf("\n\ncould not set %s. %v\n\n", cmd, returnVal.Err)
+				}
+			}
+		case "tag", "untag":
+			jobType := list.JobType(list.TagData)
+			if cmd == "untag" {
+				jobType = list.UntagData
+			}
+			fmt.Printf("\nEnter todo Item number, id or text to %s : ", cmd)
+			item, _ = reader.ReadString('\n')
+			fmt.Printf("\nnow enter the tag : ")
+			tag, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: jobType, KeyValue: stripnl(item), AltValue: stripnl(tag), ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing to do item tags", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. see log for details\n\n", cmd)
+				}
+			}
 		case "lst", "":
-			data = list.DataStoreJob{Context: ctx, Uid: uid, JobType: list.FetchData, KeyValue: "", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+			fmt.Printf("\nEnter a tag to list, or nothing for every item : ")
+			tag, _ := reader.ReadString('\n')
+			fmt.Printf("\nEnter the order, manual, priority or due, or nothing for manual : ")
+			order, _ := reader.ReadString('\n')
+			data = list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.FetchData, KeyValue: "", AltValue: "", ReturnChannel: make(chan list.ReturnChannelData)}
+			if tag = stripnl(tag); tag != "" {
+				data.JobType = list.FilterData
+				data.KeyValue = tag
+			}
 			list.DataJobQueue <- data
 			returnVal, ok = <-data.ReturnChannel
 			if ok {
+				if errors.Is(returnVal.Err, list.InvalidTagErr) || errors.Is(returnVal.Err, list.NotFoundErr) {
+					fmt.Printf("\n\n%v\n\n", returnVal.Err)
+					break
+				}
 				if returnVal.Err != nil {
 					list.Logger.ErrorContext(ctx, "Error listing to do items", "details", returnVal.Err)
 					return
 				}
-				fmt.Printf("\n%s TO DO LIST\n--------------------\n", uid)
-				for _, v := range list.SortedArray(returnVal.List) {
-					fmt.Printf("%d. %s\n", v.Id, v.Item)
+				items := list.SortedArray(returnVal.List)
+				if err := list.SortItems(items, stripnl(order)); err != nil {
+					fmt.Printf("\n\n%v\n\n", err)
+					break
+				}
+				fmt.Printf("\n%s TO DO LIST %s\n--------------------\n", uid, listName)
+				printTree(list.Tree(items), 0)
+				fmt.Printf("--------------------\n\n")
+			}
+		case "find":
+			fmt.Printf("\nEnter what to find : ")
+			query, _ := reader.ReadString('\n')
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: list.SearchData, KeyValue: stripnl(query), ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error searching to do list", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not find. %v\n\n", returnVal.Err)
+					break
+				}
+				fmt.Printf("\nFOUND %d IN %s TO DO LIST %s\n--------------------\n", len(returnVal.Found), uid, listName)
+				for _, v := range returnVal.Found {
+					printItem(v.ToDoItem, 0)
+				}
+				fmt.Printf("--------------------\n\n")
+			}
+		case "undo", "redo":
+			jobType, did := list.JobType(list.UndoData), "undid"
+			if cmd == "redo" {
+				jobType, did = list.RedoData, "redid"
+			}
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: listName, JobType: jobType, ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error running "+cmd, "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. %v\n\n", cmd, returnVal.Err)
+					break
+				}
+				fmt.Printf("\n\n%s %s\n\n", did, returnVal.Op)
+			}
+		case "use":
+			fmt.Printf("\nEnter the list to use, it is created if it doesn't exist : ")
+			name, _ := reader.ReadString('\n')
+			name = stripnl(name)
+			if name == "" || name == list.DefaultList {
+				listName = list.DefaultList
+				break
+			}
+			data := list.DataStoreJob{Context: ctx, Uid: uid, List: name, JobType: list.CreateListData, ReturnChannel: make(chan list.ReturnChannelData)}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok && returnVal.Err != nil && !errors.Is(returnVal.Err, list.AlreadyExistsErr) {
+				list.Logger.ErrorContext(ctx, "Error creating todo list", "details", returnVal.Err)
+				fmt.Printf("\n\ncould not use %s. %v\n\n", name, returnVal.Err)
+				break
+			}
+			listName = name
+		case "lists", "renlist", "dellist":
+			data := list.DataStoreJob{Context: ctx, Uid: uid, JobType: list.FetchListsData, ReturnChannel: make(chan list.ReturnChannelData)}
+			if cmd != "lists" {
+				fmt.Printf("\nEnter the list to %s : ", strings.TrimSuffix(cmd, "list"))
+				name, _ := reader.ReadString('\n')
+				data.List = stripnl(name)
+				data.JobType = list.DeleteListData
+			}
+			if cmd == "renlist" {
+				fmt.Printf("\nnow enter its new name : ")
+				newName, _ := reader.ReadString('\n')
+				data.AltValue = stripnl(newName)
+				data.JobType = list.RenameListData
+			}
+			list.DataJobQueue <- data
+			returnVal, ok := <-data.ReturnChannel
+			if ok {
+				if returnVal.Err != nil {
+					list.Logger.ErrorContext(ctx, "Error changing todo lists", "details", returnVal.Err)
+					fmt.Printf("\n\ncould not %s. %v\n\n", cmd, returnVal.Err)
+					break
+				}
+				// the list in use may have been renamed or deleted
+				if data.List == listName {
+					listName = list.DefaultList
+					if data.JobType == list.RenameListData {
+						listName = data.AltValue
+					}
+				}
+				fmt.Printf("\n%s TODO LISTS\n--------------------\n", uid)
+				for _, v := range returnVal.Lists {
+					fmt.Printf("%s\n", v)
 				}
 				fmt.Printf("--------------------\n\n")
 			}